@@ -1,15 +1,32 @@
 package io
 
 import (
+	"bytes"
 	"context"
 	"errors"
+	"fmt"
 	"io"
 	"sync"
 	"sync/atomic"
+	"time"
 )
 
 type bufferReadSeekCloserFactory struct {
-	pool Pool
+	pool            Pool
+	autoRelease     bool
+	maxReadSize     int
+	inlineThreshold int
+	retryAttempts   int
+	isRetryable     func(err error) bool
+	onFillStats     func(ratios []float64)
+	ringBuffers     int
+	onFirstByte     func(d time.Duration)
+	clampSeek       bool
+	idleTimeout     time.Duration
+	keepSeek        bool
+
+	sizedPoolsMu sync.Mutex
+	sizedPools   map[int]Pool
 }
 
 type OptionBufferReadSeekCloserFactory func(f *bufferReadSeekCloserFactory)
@@ -32,6 +49,121 @@ func OptionWithSyncPool(bufferSize int) OptionBufferReadSeekCloserFactory {
 	}
 }
 
+// OptionWithAutoRelease will disable the seeker and release the buffers once the reader is fully consumed by Read.
+// While a mark is set by BufferMarker.Mark, only the buffers before the mark are released
+func OptionWithAutoRelease() OptionBufferReadSeekCloserFactory {
+	return func(f *bufferReadSeekCloserFactory) {
+		if f == nil {
+			return
+		}
+		f.autoRelease = true
+	}
+}
+
+// OptionWithMaxReadSize will limit the size of each read from the underlying reader to n bytes
+func OptionWithMaxReadSize(n int) OptionBufferReadSeekCloserFactory {
+	return func(f *bufferReadSeekCloserFactory) {
+		if f == nil || n <= 0 {
+			return
+		}
+		f.maxReadSize = n
+	}
+}
+
+// OptionWithInlineThreshold will keep the small streams in an inline buffer instead of a pooled one, so they never
+// touch the pool. It applies to the sources reporting at most n unread bytes by Len() int, e.g. *bytes.Buffer,
+// whose data are read up to EOF by the first read. n is capped below the pool's buffer size.
+func OptionWithInlineThreshold(n int) OptionBufferReadSeekCloserFactory {
+	return func(f *bufferReadSeekCloserFactory) {
+		if f == nil || n <= 0 {
+			return
+		}
+		f.inlineThreshold = n
+	}
+}
+
+// OptionWithRetry will retry the read of the underlying reader up to maxAttempts in total when it returns an error
+// accepted by isRetryable, EOF is never retried. A nil isRetryable accepts every error.
+func OptionWithRetry(maxAttempts int, isRetryable func(err error) bool) OptionBufferReadSeekCloserFactory {
+	return func(f *bufferReadSeekCloserFactory) {
+		if f == nil || maxAttempts <= 1 {
+			return
+		}
+		f.retryAttempts = maxAttempts
+		f.isRetryable = isRetryable
+	}
+}
+
+// OptionWithFillStats will report the fill ratio (len/cap) of each buffer used by a reader. The cb is called once,
+// when the seeker is disabled or the reader is closed, whichever comes first
+func OptionWithFillStats(cb func(ratios []float64)) OptionBufferReadSeekCloserFactory {
+	return func(f *bufferReadSeekCloserFactory) {
+		if f == nil {
+			return
+		}
+		f.onFillStats = cb
+	}
+}
+
+// OptionWithRingBuffer will keep at most n buffers behind the current position, the oldest one is recycled as the
+// reading advances. A forward seek keeps the data it skips until it succeeds, so a failed seek leaves the position
+// unchanged, then only the n buffers behind its target are kept. Seeking before the oldest retained buffer returns
+// ErrSeekerOutOfRange, and resetting to a recycled mark returns ErrMarkReleased.
+// The buffers aliased by ReadSlice are dropped instead of being returned to the pool, so the views stay valid
+func OptionWithRingBuffer(n int) OptionBufferReadSeekCloserFactory {
+	return func(f *bufferReadSeekCloserFactory) {
+		if f == nil || n <= 0 {
+			return
+		}
+		f.ringBuffers = n
+	}
+}
+
+// OptionWithFirstByteLatency will report the time from the reader creation to the first byte returned by
+// the underlying reader. The data streamed by WriteTo once the seeker is disabled is not observed
+func OptionWithFirstByteLatency(cb func(d time.Duration)) OptionBufferReadSeekCloserFactory {
+	return func(f *bufferReadSeekCloserFactory) {
+		if f == nil {
+			return
+		}
+		f.onFirstByte = cb
+	}
+}
+
+// OptionWithClampSeek will move a seek beyond the end to the end instead of returning ErrSeekerOutOfRange,
+// see SeekClamper to detect the clamping
+func OptionWithClampSeek() OptionBufferReadSeekCloserFactory {
+	return func(f *bufferReadSeekCloserFactory) {
+		if f == nil {
+			return
+		}
+		f.clampSeek = true
+	}
+}
+
+// OptionWithIdleTimeout will disable the seeker and release the buffers once the reader is neither read nor seeked
+// for d, assuming the caller no longer needs to rewind. Every method reading or moving the position counts as activity,
+// except querying the position by Seek(0, io.SeekCurrent)
+func OptionWithIdleTimeout(d time.Duration) OptionBufferReadSeekCloserFactory {
+	return func(f *bufferReadSeekCloserFactory) {
+		if f == nil || d <= 0 {
+			return
+		}
+		f.idleTimeout = d
+	}
+}
+
+// OptionKeepUnderlyingSeek will make DisableSeeker a no-op for the io.ReadSeeker sources, e.g. *os.File or
+// *bytes.Reader, since they are not buffered. The seeker of the other sources is disabled as usual
+func OptionKeepUnderlyingSeek() OptionBufferReadSeekCloserFactory {
+	return func(f *bufferReadSeekCloserFactory) {
+		if f == nil {
+			return
+		}
+		f.keepSeek = true
+	}
+}
+
 func NewBufferReadSeekCloserFactory(options ...OptionBufferReadSeekCloserFactory) BufferReadSeekCloserFactory {
 	b := &bufferReadSeekCloserFactory{}
 
@@ -42,34 +174,157 @@ func NewBufferReadSeekCloserFactory(options ...OptionBufferReadSeekCloserFactory
 		option(b)
 	}
 
-	if b.pool == nil {
+	// a pool without a valid buffer size can not be indexed, it is replaced by the default one
+	if b.pool == nil || b.pool.BufferSize() <= 0 {
 		b.pool = newPool(DefaultBufferSize)
 	}
 
 	return b
 }
 
+func (b *bufferReadSeekCloserFactory) Config() FactoryConfig {
+	return FactoryConfig{
+		BufferSize:         b.pool.BufferSize(),
+		PoolType:           fmt.Sprintf("%T", b.pool),
+		AutoRelease:        b.autoRelease,
+		MaxReadSize:        b.maxReadSize,
+		InlineThreshold:    b.inlineThreshold,
+		RetryAttempts:      b.retryAttempts,
+		FillStats:          b.onFillStats != nil,
+		RingBuffers:        b.ringBuffers,
+		FirstByteLatency:   b.onFirstByte != nil,
+		ClampSeek:          b.clampSeek,
+		IdleTimeout:        b.idleTimeout,
+		KeepUnderlyingSeek: b.keepSeek,
+	}
+}
+
 func (b *bufferReadSeekCloserFactory) NewReader(r io.Reader) BufferReadSeekCloser {
-	var rc io.ReadCloser
+	return b.newReader(r, b.pool)
+}
+
+// NewReaderWithBufferSize is similar to NewReader, but the reader is using buffers of bufSize bytes.
+// The factory's pool is used when its size matches, otherwise a pool of bufSize is created and shared by the factory.
+func (b *bufferReadSeekCloserFactory) NewReaderWithBufferSize(r io.Reader, bufSize int) BufferReadSeekCloser {
+	return b.newReader(r, b.sizedPool(bufSize))
+}
+
+// NewReaderWithSizeHint is similar to NewReaderWithBufferSize, but the buffer size is picked from expectedSize.
+// The size is a power of two aiming for sizeHintBuffers buffers, so the number of pools created stays small.
+// The factory's pool is used when expectedSize is not positive.
+func (b *bufferReadSeekCloserFactory) NewReaderWithSizeHint(r io.Reader, expectedSize int64) BufferReadSeekCloser {
+	return b.newReader(r, b.sizedPool(sizeHintBufferSize(expectedSize)))
+}
+
+func sizeHintBufferSize(expectedSize int64) int {
+	if expectedSize <= 0 {
+		return 0
+	}
+
+	bufSize := MinSizeHintBufferSize
+	for bufSize < MaxSizeHintBufferSize && int64(bufSize)*sizeHintBuffers < expectedSize {
+		bufSize *= 2
+	}
+	return bufSize
+}
+
+func (b *bufferReadSeekCloserFactory) sizedPool(bufSize int) Pool {
+	if bufSize <= 0 || bufSize == b.pool.BufferSize() {
+		return b.pool
+	}
+
+	b.sizedPoolsMu.Lock()
+	defer b.sizedPoolsMu.Unlock()
+
+	p, ok := b.sizedPools[bufSize]
+	if !ok {
+		if b.sizedPools == nil {
+			b.sizedPools = make(map[int]Pool)
+		}
+		p = newPool(bufSize)
+		b.sizedPools[bufSize] = p
+	}
+	return p
+}
+
+func (b *bufferReadSeekCloserFactory) newReader(r io.Reader, pool Pool) BufferReadSeekCloser {
+	if isNilReader(r) {
+		return &nilReader{}
+	}
+
+	var (
+		rc   io.ReadCloser
+		kind string
+	)
 	switch r := r.(type) {
 	case BufferReadSeekCloser:
-		rc = r
+		rc, kind = r, SourceKindBufferReadSeekCloser
 	case io.ReadSeeker:
-		return &bufReadSeeker{readSeeker: r}
+		return &bufReadSeeker{readSeeker: r, keepSeek: b.keepSeek}
 	case io.ReadCloser:
-		rc = r
+		rc, kind = r, SourceKindReadCloser
 	default:
-		rc = NopCloser(r)
+		rc, kind = NopCloser(r), SourceKindReader
 	}
 
+	sizer := sourceSizer(r)
+
 	ctx, cancel := context.WithCancel(context.Background())
 
-	return &bufReader{
-		ctx:       ctx,
-		cancelCtx: cancel,
-		pool:      b.pool,
-		reader:    rc,
+	var createdAt time.Time
+	if b.onFirstByte != nil {
+		createdAt = time.Now()
+	}
+
+	bufSize := pool.BufferSize()
+	inlineThreshold := b.inlineThreshold
+	if inlineThreshold >= bufSize {
+		inlineThreshold = bufSize - 1
+	}
+
+	reader := &bufReader{
+		ctx:             ctx,
+		cancelCtx:       cancel,
+		pool:            pool,
+		bufSize:         bufSize,
+		autoRelease:     b.autoRelease,
+		maxReadSize:     b.maxReadSize,
+		inlineThreshold: inlineThreshold,
+		retryAttempts:   b.retryAttempts,
+		isRetryable:     b.isRetryable,
+		onFillStats:     b.onFillStats,
+		ringBuffers:     b.ringBuffers,
+		onFirstByte:     b.onFirstByte,
+		clampSeek:       b.clampSeek,
+		createdAt:       createdAt,
+		sizer:           sizer,
+		reader:          rc,
+		kind:            kind,
 	}
+	if b.idleTimeout > 0 {
+		reader.idleTimeout = b.idleTimeout
+		reader.idleTimer = time.AfterFunc(b.idleTimeout, reader.DisableSeeker)
+	}
+	return reader
+}
+
+// sourceSizer returns the source reporting its unread bytes by Len() int, e.g. *bytes.Buffer, nil for the others.
+// Size() int64 is not used, since it does not tell how much of the source is already read
+func sourceSizer(r io.Reader) interface{ Len() int } {
+	switch r := r.(type) {
+	case BufferReadSeekCloser:
+		return nil
+	case interface{ Len() int }:
+		return r
+	}
+	return nil
+}
+
+func (b *bufferReadSeekCloserFactory) NewSeekableReader(r io.Reader) (BufferReadSeekCloser, error) {
+	if isNilReader(r) {
+		return nil, ErrNilReader
+	}
+	return b.NewReader(r), nil
 }
 
 func (b *bufferReadSeekCloserFactory) BufferSize() int {
@@ -81,6 +336,8 @@ type bufReadSeeker struct {
 	isSeekerDisabled int32
 	isClosed         int32
 	currentPos       int64
+	// DisableSeeker is a no-op, see OptionKeepUnderlyingSeek
+	keepSeek bool
 
 	readSeeker io.ReadSeeker
 }
@@ -98,6 +355,41 @@ func (b *bufReadSeeker) Read(p []byte) (n int, err error) {
 	return
 }
 
+// ReadAt will read from the underlying reader directly when it implements io.ReaderAt
+func (b *bufReadSeeker) ReadAt(p []byte, off int64) (n int, err error) {
+	if atomic.LoadInt32(&b.isClosed) == 1 {
+		return 0, ErrClosed
+	}
+
+	readerAt, ok := b.readSeeker.(io.ReaderAt)
+	if !ok {
+		return 0, ErrReaderAtUnsupported
+	}
+	return readerAt.ReadAt(p, off)
+}
+
+func (b *bufReadSeeker) Remaining() (int64, bool) {
+	if atomic.LoadInt32(&b.isClosed) == 1 {
+		return 0, false
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch rs := b.readSeeker.(type) {
+	case interface{ Size() int64 }:
+		remaining := rs.Size() - b.currentPos
+		if remaining < 0 {
+			remaining = 0
+		}
+		return remaining, true
+	case interface{ Len() int }:
+		return int64(rs.Len()), true
+	default:
+		return 0, false
+	}
+}
+
 func (b *bufReadSeeker) Seek(offset int64, whence int) (int64, error) {
 	if atomic.LoadInt32(&b.isClosed) == 1 {
 		return b.currentPos, ErrClosed
@@ -117,6 +409,17 @@ func (b *bufReadSeeker) Seek(offset int64, whence int) (int64, error) {
 	return curPos, err
 }
 
+// SeekTracked is similar to Seek, the underlying reader is seekable, so nothing is ever buffered
+func (b *bufReadSeeker) SeekTracked(offset int64, whence int) (int64, int64, error) {
+	pos, err := b.Seek(offset, whence)
+	return pos, 0, err
+}
+
+// SeekBuffered is similar to Seek, the underlying reader is seekable, so the target never needs to be buffered
+func (b *bufReadSeeker) SeekBuffered(offset int64, whence int) (int64, error) {
+	return b.Seek(offset, whence)
+}
+
 func (b *bufReadSeeker) Close() error {
 	if !atomic.CompareAndSwapInt32(&b.isClosed, 0, 1) {
 		return ErrClosed
@@ -131,174 +434,1042 @@ func (b *bufReadSeeker) Close() error {
 }
 
 func (b *bufReadSeeker) DisableSeeker() {
+	_ = b.DisableSeekerE()
+}
+
+func (b *bufReadSeeker) DisableSeekerE() error {
 	if atomic.LoadInt32(&b.isClosed) == 1 {
-		return
+		return ErrClosed
+	}
+	if b.keepSeek {
+		return nil
 	}
 	if !atomic.CompareAndSwapInt32(&b.isSeekerDisabled, 0, 1) {
-		return
+		return ErrSeekerDisabled
 	}
+	return nil
+}
+
+// ForwardOnly returns a view only exposing Read and Close, see ForwardOnlyViewer
+func (b *bufReadSeeker) ForwardOnly() io.ReadCloser {
+	return &forwardOnly{rc: b}
+}
+
+// MemUsage returns zero, the underlying reader is seekable, so nothing is ever buffered
+func (b *bufReadSeeker) MemUsage() int64 {
+	return 0
+}
+
+// DisableSeekerFlush will disable the seeker, nothing is buffered, so nothing is written to w
+func (b *bufReadSeeker) DisableSeekerFlush(w io.Writer) error {
+	return b.DisableSeekerE()
+}
+
+func (b *bufReadSeeker) SourceKind() string {
+	return SourceKindReadSeeker
 }
 
 type bufReader struct {
+	// currentPos is written atomically while holding the mu, so it can be read without the mu by getPos.
+	// It is the first field to keep the 64-bit alignment required by the atomic operations
+	currentPos int64
+
 	mu sync.Mutex
 
-	ctx              context.Context
-	cancelCtx        context.CancelFunc
-	pool             Pool
+	ctx             context.Context
+	cancelCtx       context.CancelFunc
+	pool            Pool
+	bufSize         int
+	autoRelease     bool
+	maxReadSize     int
+	inlineThreshold int
+	retryAttempts   int
+	isRetryable     func(err error) bool
+	isInline        bool
+	isMarked        bool
+	markPos         int64
+	onFillStats     func(ratios []float64)
+	ringBuffers     int
+	onFirstByte     func(d time.Duration)
+	createdAt       time.Time
+	clampSeek       bool
+	idleTimeout     time.Duration
+	idleTimer       *time.Timer
+	// index of the oldest buffer retained, the older ones are recycled by OptionWithRingBuffer
+	// or released before the mark by OptionWithAutoRelease
+	firstRetained int
+	// index of the buffer held by buffer[0], the released buffers before it are dropped from the index by Compact
+	bufferBase int
+	// number of the leading buffers aliased by ReadSlice
+	slicedBuffers int
+	// size of the stream discovered at EOF, see knownSize
+	size        int64
+	isSizeKnown bool
+	// source reporting its unread bytes, so the seek relative to the end does not need to buffer the whole stream
+	sizer            interface{ Len() int }
 	isSeekerDisabled int32
 	isClosed         int32
 	isEofReached     bool
 	reader           io.ReadCloser
+	kind             string
 	buffer           []*Buffer
-
-	currentPos int64
+	// data read by an aborted ReadCtx while the seeker is disabled
+	pending    []byte
+	pendingErr error
+	// the last bytes returned by Read once the seeker is disabled by DisableSeekerKeepTail,
+	// the last tailUnread bytes of the tail are returned again by the next Read
+	tail       []byte
+	tailSize   int
+	tailUnread int
 }
 
 func (b *bufReader) DisableSeeker() {
+	_ = b.DisableSeekerE()
+}
+
+func (b *bufReader) SourceKind() string {
+	return b.kind
+}
+
+func (b *bufReader) DisableSeekerE() error {
 	if atomic.LoadInt32(&b.isClosed) == 1 {
-		return
+		return ErrClosed
 	}
 	if !atomic.CompareAndSwapInt32(&b.isSeekerDisabled, 0, 1) {
-		return
+		return ErrSeekerDisabled
 	}
 
 	b.mu.Lock()
 	defer b.mu.Unlock()
 
+	b.reportFillStats()
 	// cleanup unused buffer
 	b.cleanUpBuffer(false)
+	return nil
 }
 
-func (b *bufReader) Seek(offset int64, whence int) (int64, error) {
+// DisableSeekerKeepTail is similar to DisableSeekerE, but the last n bytes returned by Read are retained, see TailKeeper
+func (b *bufReader) DisableSeekerKeepTail(n int) error {
 	if atomic.LoadInt32(&b.isClosed) == 1 {
-		return b.currentPos, ErrClosed
+		return ErrClosed
 	}
-	if atomic.LoadInt32(&b.isSeekerDisabled) == 1 {
-		return b.currentPos, ErrSeekerDisabled
+	if !atomic.CompareAndSwapInt32(&b.isSeekerDisabled, 0, 1) {
+		return ErrSeekerDisabled
+	}
+	if n < 0 {
+		n = 0
 	}
 
 	b.mu.Lock()
 	defer b.mu.Unlock()
 
-	var abs int64
+	b.reportFillStats()
+	b.tailSize = n
+	b.tail = b.bufferedBefore(n)
+	b.cleanUpBuffer(false)
+	return nil
+}
 
-	switch whence {
-	case io.SeekStart:
-		abs = offset
-	case io.SeekCurrent:
-		abs = b.currentPos + offset
-	case io.SeekEnd:
-		if offset > 0 {
-			return b.currentPos, ErrSeekerOutOfRange
-		}
-		_, err := b.read(-1)
-		if err != nil && !errors.Is(err, io.EOF) {
-			return b.currentPos, err
-		}
-		abs = b.getReaderPos() + offset
-	default:
-		return b.currentPos, ErrSeekerInvalidWhence
+// UnreadBytes will move back n bytes, so they are returned again by the next Read, see TailKeeper
+func (b *bufReader) UnreadBytes(n int) error {
+	if atomic.LoadInt32(&b.isClosed) == 1 {
+		return ErrClosed
 	}
 
-	if abs < 0 {
-		return b.currentPos, ErrSeekerOutOfRange
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if atomic.LoadInt32(&b.isSeekerDisabled) == 0 {
+		_, _, err := b.seek(-int64(n), io.SeekCurrent)
+		return err
 	}
+	if n < 0 || n > len(b.tail)-b.tailUnread {
+		return ErrSeekerDisabled
+	}
+	b.tailUnread += n
+	return nil
+}
 
-	bytesToRead := abs - b.getReaderPos()
-	if bytesToRead > 0 {
-		n, err := b.read(bytesToRead)
-		if err != nil && !errors.Is(err, io.EOF) {
-			return b.currentPos, err
+// bufferedBefore returns a copy of at most n buffered bytes right before the current position
+func (b *bufReader) bufferedBefore(n int) []byte {
+	pos := b.currentPos - int64(n)
+	if pos < 0 {
+		pos = 0
+	}
+
+	tail := make([]byte, 0, b.currentPos-pos)
+	for pos < b.currentPos {
+		buffer := b.bufferOf(int(pos / int64(b.bufSize)))
+		if buffer == nil {
+			return nil
 		}
-		if n < bytesToRead {
-			return b.currentPos, ErrSeekerOutOfRange
+		buf := buffer.buffer
+		start := int(pos % int64(b.bufSize))
+		end := len(buf)
+		if remaining := b.currentPos - pos; int64(end-start) > remaining {
+			end = start + int(remaining)
 		}
+		tail = append(tail, buf[start:end]...)
+		pos += int64(end - start)
 	}
+	return tail
+}
 
-	b.currentPos = abs
-	return abs, nil
+// keepTail will append the data returned by Read to the tail, trimmed to the tailSize
+func (b *bufReader) keepTail(data []byte) {
+	if b.tailSize <= 0 || len(data) == 0 {
+		return
+	}
+	b.tail = append(b.tail, data...)
+	if len(b.tail) > b.tailSize {
+		b.tail = append(b.tail[:0], b.tail[len(b.tail)-b.tailSize:]...)
+	}
 }
 
-func (b *bufReader) Read(p []byte) (int, error) {
+// DisableSeekerFlush will write the buffered data from the current position to w, then release all buffers and
+// disable the seeker. If the write fails, the unwritten data stays buffered for the next Read.
+func (b *bufReader) DisableSeekerFlush(w io.Writer) error {
 	if atomic.LoadInt32(&b.isClosed) == 1 {
-		return 0, ErrClosed
+		return ErrClosed
+	}
+	if !atomic.CompareAndSwapInt32(&b.isSeekerDisabled, 0, 1) {
+		return ErrSeekerDisabled
 	}
 
 	b.mu.Lock()
 	defer b.mu.Unlock()
 
-	n := 0
-
-	if b.currentPos < b.getReaderPos() {
-		// get data from buffer
-		tmpN, err := b.readTo(p)
-		n += tmpN
-		if err != nil {
-			return n, err
-		}
+	b.reportFillStats()
+	_, err := b.writeBufferedTo(w)
+	if err != nil {
+		b.cleanUpBuffer(false)
+		return err
 	}
 
-	if n == len(p) {
-		return n, nil
-	}
+	b.cleanUpBuffer(true)
+	return nil
+}
 
-	// if seeker is disabled, read the data directly
-	if atomic.LoadInt32(&b.isSeekerDisabled) == 1 {
-		// cleanup all unused buffer
-		defer b.cleanUpBuffer(true)
+func (b *bufReader) Seek(offset int64, whence int) (int64, error) {
+	pos, _, err := b.SeekTracked(offset, whence)
+	return pos, err
+}
 
-		tmpN, err := b.reader.Read(p[n:])
-		n += tmpN
-		return n, err
+// ReadSlice returns a view of at most n bytes of the buffered data without copying, see SliceReader.
+// It returns ErrSeekerDisabled once the seeker is disabled, since the buffers are released.
+func (b *bufReader) ReadSlice(n int) ([]byte, error) {
+	if atomic.LoadInt32(&b.isClosed) == 1 {
+		return nil, ErrClosed
 	}
-
-	tmpN, err := b.read(int64(len(p[n:])))
-	if tmpN > 0 {
-		var realN int
-		realN, err = b.readTo(p[n:]) // reassign error
-		n += realN
+	if atomic.LoadInt32(&b.isSeekerDisabled) == 1 {
+		return nil, ErrSeekerDisabled
 	}
-	if err != nil {
-		return n, err
+	if n <= 0 {
+		return nil, nil
 	}
 
-	return n, nil
-}
+	b.resetIdleTimer()
 
-// copy data from buffer to p
-func (b *bufReader) readTo(p []byte) (n int, err error) {
-	for {
-		switch {
-		case b.currentPos >= b.getReaderPos():
-			if b.isEofReached && n == 0 {
-				err = io.EOF
-			}
-			return
-		case n == len(p):
-			return
-		case atomic.LoadInt32(&b.isClosed) == 1:
-			err = ErrClosed
-			return
-		}
+	b.mu.Lock()
+	defer b.mu.Unlock()
 
-		buf := b.buffer[b.currentPos/int64(b.pool.BufferSize())]
-		currentPos := int(b.currentPos % int64(b.pool.BufferSize()))
+	if b.currentPos >= b.getReaderPos() {
+		_, err := b.readData(int64(n))
+		if err != nil && b.currentPos >= b.getReaderPos() {
+			return nil, err
+		}
+	}
 
-		read := copy(p[n:], buf.buffer[currentPos:])
-		n += read
-		b.currentPos += int64(read)
+	buf, err := b.bufferAt(b.currentPos)
+	if err != nil {
+		return nil, err
 	}
+	if len(buf) > n {
+		buf = buf[:n]
+	}
+	if sliced := int(b.currentPos/int64(b.bufSize)) + 1; sliced > b.slicedBuffers {
+		b.slicedBuffers = sliced
+	}
+
+	atomic.AddInt64(&b.currentPos, int64(len(buf)))
+	return buf[:len(buf):len(buf)], nil
 }
 
-// put data from underlying reader to buffer
-func (b *bufReader) read(n int64) (bytesRead int64, err error) {
+// ForwardOnly returns a view only exposing Read and Close, so it can be handed out without risking a seek.
+// The view shares the position and the buffers of b
+func (b *bufReader) ForwardOnly() io.ReadCloser {
+	return &forwardOnly{rc: b}
+}
+
+// Compact will drop the buffers released by OptionWithRingBuffer or OptionWithAutoRelease from the buffer index,
+// so the index of a long stream does not keep growing, see Compacter. The read path always fills a buffer before
+// taking the next one, so only the last buffer may be partly filled and the retained data is not copied.
+// The current position and the retained data are kept
+func (b *bufReader) Compact() error {
+	if atomic.LoadInt32(&b.isClosed) == 1 {
+		return ErrClosed
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	// the last buffer is kept even if it is released, so the end of the buffered data stays known
+	drop := b.firstRetained - b.bufferBase
+	if drop > len(b.buffer)-1 {
+		drop = len(b.buffer) - 1
+	}
+	if drop <= 0 && cap(b.buffer) == len(b.buffer) {
+		return nil
+	}
+	if drop < 0 {
+		drop = 0
+	}
+
+	b.buffer = append([]*Buffer(nil), b.buffer[drop:]...)
+	b.bufferBase += drop
+	return nil
+}
+
+// MemUsage returns the number of bytes currently retained in the buffers
+func (b *bufReader) MemUsage() int64 {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	var usage int64
+	for _, buf := range b.buffer {
+		if buf == nil {
+			continue
+		}
+		usage += int64(cap(buf.buffer))
+	}
+	usage += int64(cap(b.tail))
+	return usage
+}
+
+// Mark will remember the current position, the data from the mark is not released by OptionWithAutoRelease
+// until the mark is consumed by Reset
+func (b *bufReader) Mark() (int64, error) {
+	if atomic.LoadInt32(&b.isClosed) == 1 {
+		return b.getPos(), ErrClosed
+	}
+	if atomic.LoadInt32(&b.isSeekerDisabled) == 1 {
+		return b.getPos(), ErrSeekerDisabled
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.isMarked = true
+	b.markPos = b.currentPos
+	return b.currentPos, nil
+}
+
+// Reset will seek back to the position returned by Mark and consume the mark.
+// It returns ErrMarkReleased once the seeker is disabled, since the buffered data is released.
+func (b *bufReader) Reset(token int64) error {
+	if atomic.LoadInt32(&b.isClosed) == 1 {
+		return ErrClosed
+	}
+	if atomic.LoadInt32(&b.isSeekerDisabled) == 1 {
+		return ErrMarkReleased
+	}
+
+	b.resetIdleTimer()
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if token < b.getFirstRetainedPos() {
+		// recycled by OptionWithRingBuffer
+		return ErrMarkReleased
+	}
+	if token > b.getReaderPos() {
+		return ErrSeekerOutOfRange
+	}
+	atomic.StoreInt64(&b.currentPos, token)
+	b.isMarked = false
+	return nil
+}
+
+// SeekTracked is similar to Seek, but it also returns the number of bytes read from the underlying reader by the seek
+func (b *bufReader) SeekTracked(offset int64, whence int) (pos int64, bufferedBytes int64, err error) {
+	if atomic.LoadInt32(&b.isClosed) == 1 {
+		return b.getPos(), 0, ErrClosed
+	}
+	if atomic.LoadInt32(&b.isSeekerDisabled) == 1 {
+		return b.getPos(), 0, ErrSeekerDisabled
+	}
+	if offset == 0 && whence == io.SeekCurrent {
+		// fast path for querying the current position, neither the mu nor the idle timer is touched
+		return b.getPos(), 0, nil
+	}
+	b.resetIdleTimer()
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	readerPos := b.getReaderPos()
+	pos, _, err = b.seek(offset, whence)
+	bufferedBytes = b.getReaderPos() - readerPos
+	return
+}
+
+// SeekBuffered is similar to Seek, but it only moves within the buffered data, see BufferedSeeker
+func (b *bufReader) SeekBuffered(offset int64, whence int) (int64, error) {
+	if atomic.LoadInt32(&b.isClosed) == 1 {
+		return b.getPos(), ErrClosed
+	}
+	if atomic.LoadInt32(&b.isSeekerDisabled) == 1 {
+		return b.getPos(), ErrSeekerDisabled
+	}
+
+	b.resetIdleTimer()
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	size, isSizeKnown := b.knownSize()
+	var abs int64
+
+	switch whence {
+	case io.SeekStart:
+		abs = offset
+	case io.SeekCurrent:
+		abs = b.currentPos + offset
+	case io.SeekEnd:
+		if offset > 0 {
+			return b.currentPos, ErrSeekerOutOfRange
+		}
+		if !isSizeKnown {
+			return b.currentPos, ErrNotBuffered
+		}
+		abs = size + offset
+	default:
+		return b.currentPos, ErrSeekerInvalidWhence
+	}
+
+	if abs < b.getFirstRetainedPos() || (isSizeKnown && abs > size) {
+		return b.currentPos, ErrSeekerOutOfRange
+	}
+	if abs > b.getReaderPos() {
+		return b.currentPos, ErrNotBuffered
+	}
+
+	atomic.StoreInt64(&b.currentPos, abs)
+	return abs, nil
+}
+
+// SeekClamped is similar to Seek, but it also reports whether the seek beyond the end is moved to the end
+// by OptionWithClampSeek, see SeekClamper
+func (b *bufReader) SeekClamped(offset int64, whence int) (pos int64, clamped bool, err error) {
+	if atomic.LoadInt32(&b.isClosed) == 1 {
+		return b.getPos(), false, ErrClosed
+	}
+	if atomic.LoadInt32(&b.isSeekerDisabled) == 1 {
+		return b.getPos(), false, ErrSeekerDisabled
+	}
+
+	b.resetIdleTimer()
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return b.seek(offset, whence)
+}
+
+// Fork will return an independent reader over the data from the current position to the end, see Forker
+func (b *bufReader) Fork() (BufferReadSeekCloser, error) {
+	if atomic.LoadInt32(&b.isClosed) == 1 {
+		return nil, ErrClosed
+	}
+	if atomic.LoadInt32(&b.isSeekerDisabled) == 1 {
+		return nil, ErrSeekerDisabled
+	}
+
+	b.resetIdleTimer()
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	_, err := b.readData(-1)
+	if err != nil && !errors.Is(err, io.EOF) {
+		return nil, err
+	}
+
+	data := make([]byte, 0, b.getReaderPos()-b.currentPos)
+	for pos := b.currentPos; pos < b.getReaderPos(); {
+		buf, err := b.bufferAt(pos)
+		if err != nil {
+			return nil, err
+		}
+		data = append(data, buf...)
+		pos += int64(len(buf))
+	}
+	return &bufReadSeeker{readSeeker: bytes.NewReader(data)}, nil
+}
+
+// ReadReverse will read the bytes preceding the current position in reverse order, see ReverseReader
+func (b *bufReader) ReadReverse(p []byte) (int, error) {
+	if atomic.LoadInt32(&b.isClosed) == 1 {
+		return 0, ErrClosed
+	}
+	if atomic.LoadInt32(&b.isSeekerDisabled) == 1 {
+		return 0, ErrSeekerDisabled
+	}
+	if len(p) == 0 {
+		return 0, nil
+	}
+
+	b.resetIdleTimer()
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if !b.isEofReached {
+		_, err := b.readData(-1)
+		if err != nil && !errors.Is(err, io.EOF) {
+			return 0, err
+		}
+	}
+
+	n := 0
+	for n < len(p) && b.currentPos > b.getFirstRetainedPos() {
+		pos := b.currentPos - 1
+		if _, err := b.bufferAt(pos); err != nil {
+			return n, err
+		}
+		chunk := b.bufferOf(int(pos / int64(b.bufSize))).buffer[:pos%int64(b.bufSize)+1]
+		for i := len(chunk) - 1; i >= 0 && n < len(p); i-- {
+			p[n] = chunk[i]
+			n++
+			atomic.AddInt64(&b.currentPos, -1)
+		}
+	}
+
+	switch {
+	case n > 0:
+		return n, nil
+	case b.currentPos == 0:
+		return 0, io.EOF
+	default:
+		// the data before the oldest buffer retained by OptionWithRingBuffer
+		return 0, ErrSeekerOutOfRange
+	}
+}
+
+// SeekFraction will seek to the fraction f of the stream, see FractionSeeker
+func (b *bufReader) SeekFraction(f float64) (int64, error) {
+	if atomic.LoadInt32(&b.isClosed) == 1 {
+		return b.getPos(), ErrClosed
+	}
+	if atomic.LoadInt32(&b.isSeekerDisabled) == 1 {
+		return b.getPos(), ErrSeekerDisabled
+	}
+	if f < 0 || f > 1 {
+		return b.getPos(), ErrSeekerOutOfRange
+	}
+
+	b.resetIdleTimer()
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	size, ok := b.knownSize()
+	if !ok {
+		return b.currentPos, ErrSizeUnknown
+	}
+	pos, _, err := b.seek(int64(f*float64(size)), io.SeekStart)
+	return pos, err
+}
+
+func (b *bufReader) seek(offset int64, whence int) (pos int64, clamped bool, err error) {
+	if b.ringBuffers > 0 {
+		defer func() {
+			if err == nil {
+				// the data skipped by the seek is recycled once the position is moved
+				b.recycleRing(b.ringBuffers)
+			}
+		}()
+	}
+
+	var abs int64
+
+	switch whence {
+	case io.SeekStart:
+		abs = offset
+	case io.SeekCurrent:
+		abs = b.currentPos + offset
+	case io.SeekEnd:
+		if offset > 0 && !b.clampSeek {
+			return b.currentPos, false, ErrSeekerOutOfRange
+		}
+		size, ok := b.knownSize()
+		if !ok {
+			_, err := b.read(-1)
+			if err != nil && !errors.Is(err, io.EOF) {
+				return b.currentPos, false, err
+			}
+			size = b.size
+		}
+		abs = size + offset
+	default:
+		return b.currentPos, false, ErrSeekerInvalidWhence
+	}
+
+	if abs < b.getFirstRetainedPos() {
+		return b.currentPos, false, ErrSeekerOutOfRange
+	}
+
+	bytesToRead := abs - b.getReaderPos()
+	if bytesToRead > 0 {
+		n, err := b.read(bytesToRead)
+		if err != nil && !errors.Is(err, io.EOF) {
+			return b.currentPos, false, err
+		}
+		if n < bytesToRead && b.clampSeek {
+			atomic.StoreInt64(&b.currentPos, b.getReaderPos())
+			return b.currentPos, true, nil
+		}
+		if n < bytesToRead {
+			return b.currentPos, false, ErrSeekerOutOfRange
+		}
+	}
+
+	atomic.StoreInt64(&b.currentPos, abs)
+	return abs, false, nil
+}
+
+// Read implements io.Reader. A zero-length p returns (0, nil) without touching the underlying reader or buffers
+func (b *bufReader) Read(p []byte) (int, error) {
+	if atomic.LoadInt32(&b.isClosed) == 1 {
+		return 0, ErrClosed
+	}
+	b.resetIdleTimer()
+	if len(p) == 0 {
+		return 0, nil
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	defer b.releaseIfDrained()
+	// the data retained by a failed seek is recycled as it is read
+	defer b.recycleRing(b.ringBuffers)
+
+	// DisableSeeker flips the flag before waiting for the lock, so it is read once to keep the whole read
+	// either buffered or direct
+	isSeekerDisabled := atomic.LoadInt32(&b.isSeekerDisabled) == 1
+
+	n := 0
+
+	if b.tailUnread > 0 {
+		// get data moved back by UnreadBytes
+		n = copy(p, b.tail[len(b.tail)-b.tailUnread:])
+		b.tailUnread -= n
+		if n == len(p) {
+			return n, nil
+		}
+	}
+	tailStart := n
+	defer func() {
+		b.keepTail(p[tailStart:n])
+	}()
+
+	if b.currentPos < b.getReaderPos() {
+		// get data from buffer
+		tmpN, err := b.readTo(p[n:])
+		n += tmpN
+		if err != nil {
+			return n, err
+		}
+	}
+
+	if n == len(p) {
+		return n, nil
+	}
+
+	// if seeker is disabled, read the data directly
+	if isSeekerDisabled {
+		// cleanup all unused buffer
+		defer b.cleanUpBuffer(true)
+
+		if b.hasPending() {
+			tmpN, err := b.readPending(p[n:])
+			n += tmpN
+			return n, err
+		}
+
+		tmpN, err := b.readSource(b.limitReadSize(p[n:]))
+		n += tmpN
+		if err != nil && atomic.LoadInt32(&b.isClosed) == 1 {
+			// the read is interrupted by Close
+			err = ErrClosed
+		}
+		return n, err
+	}
+
+	for {
+		toRead := int64(len(p[n:]))
+		if b.ringBuffers > 0 && toRead > int64(b.bufSize) {
+			// read buffer by buffer, so the ring recycles the buffers already copied
+			toRead = int64(b.bufSize)
+		}
+		tmpN, err := b.readData(toRead)
+		readErr := err
+		if tmpN > 0 {
+			var realN int
+			realN, err = b.readTo(p[n:]) // reassign error
+			n += realN
+		}
+		if err != nil {
+			return n, err
+		}
+		if n == len(p) || readErr != nil || b.isEofReached {
+			// the error or EOF of the underlying read is reported by the next Read
+			return n, nil
+		}
+	}
+}
+
+func (b *bufReader) ReadCtx(ctx context.Context, p []byte) (int, error) {
+	if ctx == nil {
+		return b.Read(p)
+	}
+	if atomic.LoadInt32(&b.isClosed) == 1 {
+		return 0, ErrClosed
+	}
+	if len(p) == 0 {
+		return 0, nil
+	}
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+
+	// the underlying read is done in the background without moving the current position,
+	// so an aborted read does not lose any data
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+
+		b.mu.Lock()
+		defer b.mu.Unlock()
+
+		if atomic.LoadInt32(&b.isSeekerDisabled) == 1 {
+			if b.currentPos < b.getReaderPos() || b.hasPending() {
+				return
+			}
+			pending := make([]byte, len(p))
+			n, err := b.readSource(b.limitReadSize(pending))
+			b.pending, b.pendingErr = pending[:n], err
+			return
+		}
+
+		if b.currentPos >= b.getReaderPos() {
+			// the error is reported again by the following Read
+			_, _ = b.read(int64(len(p)))
+		}
+	}()
+
+	select {
+	case <-ctx.Done():
+		return 0, ctx.Err()
+	case <-done:
+	}
+
+	return b.Read(p)
+}
+
+// copy the data read by an aborted ReadCtx
+func (b *bufReader) readPending(p []byte) (n int, err error) {
+	n = copy(p, b.pending)
+	b.pending = b.pending[n:]
+	if len(b.pending) == 0 {
+		err = b.pendingErr
+		b.pending, b.pendingErr = nil, nil
+	}
+	return
+}
+
+func (b *bufReader) hasPending() bool {
+	return len(b.pending) > 0 || b.pendingErr != nil
+}
+
+func (b *bufReader) Remaining() (int64, bool) {
+	if atomic.LoadInt32(&b.isClosed) == 1 || atomic.LoadInt32(&b.isSeekerDisabled) == 1 {
+		return 0, false
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	size, ok := b.knownSize()
+	if !ok {
+		return 0, false
+	}
+	return size - b.currentPos, true
+}
+
+// WriteTo implements io.WriterTo. The buffered data is written directly from the underlying buffers.
+// If the seeker is disabled and the underlying reader implements io.WriterTo, the rest of the data is streamed by it.
+func (b *bufReader) WriteTo(w io.Writer) (n int64, err error) {
+	if atomic.LoadInt32(&b.isClosed) == 1 {
+		return 0, ErrClosed
+	}
+
+	b.resetIdleTimer()
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	defer b.releaseIfDrained()
+
+	err = b.checkBufferSize()
+	if err != nil {
+		return
+	}
+
+	if b.tailUnread > 0 {
+		// write the data moved back by UnreadBytes
+		var written int
+		written, err = w.Write(b.tail[len(b.tail)-b.tailUnread:])
+		n += int64(written)
+		b.tailUnread -= written
+		if err == nil && b.tailUnread > 0 {
+			err = io.ErrShortWrite
+		}
+		if err != nil {
+			return
+		}
+	}
+	// the data written to w is not kept in the tail
+	b.tail = nil
+
+	var buffered int64
+	buffered, err = b.writeBufferedTo(w)
+	n += buffered
+	if err != nil {
+		return
+	}
+
+	// if seeker is disabled, stream the data directly
+	if atomic.LoadInt32(&b.isSeekerDisabled) == 1 {
+		// cleanup all unused buffer
+		defer b.cleanUpBuffer(true)
+
+		if b.hasPending() {
+			var written int
+			written, err = w.Write(b.pending)
+			n += int64(written)
+			b.pending = b.pending[written:]
+			if err == nil && len(b.pending) > 0 {
+				err = io.ErrShortWrite
+			}
+			if err != nil {
+				return
+			}
+
+			err = b.pendingErr
+			b.pending, b.pendingErr = nil, nil
+			if errors.Is(err, io.EOF) {
+				return n, nil
+			}
+			if err != nil {
+				return
+			}
+		}
+
+		var tmpN int64
+		if wt, ok := b.reader.(io.WriterTo); ok {
+			tmpN, err = wt.WriteTo(w)
+			n += tmpN
+			return
+		}
+
+		var buf *Buffer
+		buf, err = b.pool.Get(b.ctx)
+		if err != nil {
+			if errors.Is(err, context.Canceled) {
+				err = ErrClosed
+			}
+			return
+		}
+		defer buf.cleanUp()
+
+		// hide io.ReaderFrom and io.WriterTo, so the pooled buffer is always used
+		tmpN, err = io.CopyBuffer(struct{ io.Writer }{w}, struct{ io.Reader }{b.reader}, b.limitReadSize(buf.buffer))
+		n += tmpN
+		return
+	}
+
+	for {
+		_, err = b.readData(int64(b.bufSize))
+
+		tmpN, wErr := b.writeBufferedTo(w)
+		n += tmpN
+		if wErr != nil {
+			return n, wErr
+		}
+
+		if errors.Is(err, io.EOF) {
+			return n, nil
+		}
+		if err != nil {
+			return
+		}
+	}
+}
+
+// DrainTo will disable the seeker, then forward the buffered data followed by the rest of the stream, see WriteTo
+func (b *bufReader) DrainTo(w io.Writer) (int64, error) {
+	err := b.DisableSeekerE()
+	if err != nil && !errors.Is(err, ErrSeekerDisabled) {
+		return 0, err
+	}
+	return b.WriteTo(w)
+}
+
+// CopyBufferedThenStream is an alias of DrainTo, see BufferStreamer
+func (b *bufReader) CopyBufferedThenStream(w io.Writer) (int64, error) {
+	return b.DrainTo(w)
+}
+
+// Append will switch the underlying reader to r, the previous reader is closed.
+// It returns ErrSourceNotExhausted if the previous reader has not reached EOF.
+func (b *bufReader) Append(r io.Reader) error {
+	if atomic.LoadInt32(&b.isClosed) == 1 {
+		return ErrClosed
+	}
+	if atomic.LoadInt32(&b.isSeekerDisabled) == 1 {
+		return ErrSeekerDisabled
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if !b.isEofReached {
+		return ErrSourceNotExhausted
+	}
+
+	rc, ok := r.(io.ReadCloser)
+	if !ok {
+		rc = NopCloser(r)
+	}
+
+	prev := b.reader
+	b.reader = rc
+	b.sizer = sourceSizer(r)
+	b.isEofReached = false
+	b.isSizeKnown = false
+	return prev.Close()
+}
+
+// write data from buffer to w
+func (b *bufReader) writeBufferedTo(w io.Writer) (n int64, err error) {
+	for b.currentPos < b.getReaderPos() {
+		var buf []byte
+		buf, err = b.bufferAt(b.currentPos)
+		if err != nil {
+			return
+		}
+
+		var written int
+		written, err = w.Write(buf)
+		n += int64(written)
+		atomic.AddInt64(&b.currentPos, int64(written))
+		if err != nil {
+			return
+		}
+		if written < len(buf) {
+			err = io.ErrShortWrite
+			return
+		}
+	}
+	return
+}
+
+// copy data from buffer to p
+func (b *bufReader) readTo(p []byte) (n int, err error) {
+	err = b.checkBufferSize()
+	if err != nil {
+		return
+	}
+
+	for {
+		switch {
+		case b.currentPos >= b.getReaderPos():
+			if b.isEofReached && n == 0 {
+				err = io.EOF
+			}
+			return
+		case n == len(p):
+			return
+		case atomic.LoadInt32(&b.isClosed) == 1:
+			err = ErrClosed
+			return
+		}
+
+		var buf []byte
+		buf, err = b.bufferAt(b.currentPos)
+		if err != nil {
+			return
+		}
+
+		read := copy(p[n:], buf)
+		n += read
+		atomic.AddInt64(&b.currentPos, int64(read))
+	}
+}
+
+// bufferAt returns the buffered data from pos to the end of its buffer. A position not backed by a buffer is a bug,
+// it is reported as ErrInternalState instead of panicking
+func (b *bufReader) bufferAt(pos int64) ([]byte, error) {
+	var buffer *Buffer
+	if pos >= 0 {
+		buffer = b.bufferOf(int(pos / int64(b.bufSize)))
+	}
+	if buffer == nil {
+		return nil, fmt.Errorf("%w: no buffer at position %d", ErrInternalState, pos)
+	}
+	buf := buffer.buffer
+	offset := int(pos % int64(b.bufSize))
+	if offset >= len(buf) {
+		return nil, fmt.Errorf("%w: position %d is beyond its buffer of %d bytes", ErrInternalState, pos, len(buf))
+	}
+	return buf[offset:], nil
+}
+
+// readData is similar to read, but the error of the underlying reader is returned as is, only seek reports it as
+// ErrSourceRead
+func (b *bufReader) readData(n int64) (int64, error) {
+	bytesRead, err := b.read(n)
+	var srcErr *sourceReadError
+	if errors.As(err, &srcErr) {
+		err = srcErr.err
+	}
+	return bytesRead, err
+}
+
+// put data from underlying reader to buffer, the error of the underlying reader is wrapped by sourceReadError
+func (b *bufReader) read(n int64) (bytesRead int64, err error) {
+	err = b.checkBufferSize()
+	if err != nil {
+		return
+	}
+
+	if b.inlineThreshold > 0 && len(b.buffer) == 0 && b.bufferBase == 0 && !b.isEofReached && atomic.LoadInt32(&b.isSeekerDisabled) == 0 {
+		bytesRead, err = b.readInline()
+	}
+
 	for {
 		switch {
 		case b.isEofReached:
 			err = io.EOF
 			return
 		case errors.Is(err, io.EOF):
+			// the source may return the last data along with EOF, the data is kept in the buffer
+			// and EOF is reported by the next read once the buffered data is consumed
 			b.isEofReached = true
+			// the end is discovered, so the following seeks relative to the end do not need to read
+			b.size = b.getReaderPos()
+			b.isSizeKnown = true
 			if bytesRead > 0 {
 				err = nil
 			}
@@ -315,7 +1486,14 @@ func (b *bufReader) read(n int64) (bytesRead int64, err error) {
 		if len(b.buffer) != 0 {
 			buf = b.buffer[len(b.buffer)-1]
 		}
+		if b.isInline {
+			buf, err = b.promoteInline()
+			if err != nil {
+				return
+			}
+		}
 		if buf == nil || len(buf.buffer) == cap(buf.buffer) {
+			b.recycleRing(b.ringBuffers - 1)
 			buf, err = b.pool.Get(b.ctx)
 			if err != nil {
 				if errors.Is(err, context.Canceled) {
@@ -329,7 +1507,14 @@ func (b *bufReader) read(n int64) (bytesRead int64, err error) {
 		}
 
 		var tmpN int
-		tmpN, err = b.reader.Read(buf.buffer[len(buf.buffer):cap(buf.buffer)])
+		tmpN, err = b.readSource(b.limitReadSize(buf.buffer[len(buf.buffer):cap(buf.buffer)]))
+		switch {
+		case err != nil && atomic.LoadInt32(&b.isClosed) == 1:
+			// the read is interrupted by Close
+			err = ErrClosed
+		case err != nil && !errors.Is(err, io.EOF):
+			err = &sourceReadError{err: err}
+		}
 		if tmpN > 0 {
 			buf.buffer = buf.buffer[:len(buf.buffer)+tmpN]
 			bytesRead += int64(tmpN)
@@ -337,6 +1522,126 @@ func (b *bufReader) read(n int64) (bytesRead int64, err error) {
 	}
 }
 
+// readInline does the first read of a small source into an inline buffer instead of a pooled one. It only applies
+// when the source reports at most inlineThreshold unread bytes, so the other streams do not pay for the allocation.
+// The inline buffer is kept once EOF is reached, otherwise the data is moved into a pooled buffer
+func (b *bufReader) readInline() (bytesRead int64, err error) {
+	if b.sizer == nil {
+		return
+	}
+	size := b.sizer.Len()
+	if size > b.inlineThreshold {
+		return
+	}
+
+	// the spare byte confirms EOF for the source not returning it along with the last data
+	inline := make([]byte, size+1)
+	filled := 0
+	for filled < size && err == nil {
+		var tmpN int
+		tmpN, err = b.readSource(b.limitReadSize(inline[filled:size]))
+		filled += tmpN
+		if tmpN == 0 {
+			break
+		}
+	}
+	if filled == size && err == nil {
+		var tmpN int
+		tmpN, err = b.readSource(inline[filled:])
+		filled += tmpN
+	}
+	switch {
+	case err != nil && atomic.LoadInt32(&b.isClosed) == 1:
+		err = ErrClosed
+	case err != nil && !errors.Is(err, io.EOF):
+		err = &sourceReadError{err: err}
+	}
+	if filled == 0 {
+		return
+	}
+
+	buf := NewBuffer(discardPool{}, inline)
+	buf.buffer = buf.buffer[:filled]
+	b.buffer = append(b.buffer, buf)
+	b.isInline = true
+	bytesRead = int64(filled)
+
+	if !errors.Is(err, io.EOF) {
+		// the source keeps growing, move the data into a pooled buffer
+		_, promoteErr := b.promoteInline()
+		if err == nil {
+			err = promoteErr
+		}
+	}
+	return
+}
+
+// promoteInline replaces the inline buffer by a pooled one, so the stream can keep growing
+func (b *bufReader) promoteInline() (*Buffer, error) {
+	buf, err := b.pool.Get(b.ctx)
+	if err != nil {
+		if errors.Is(err, context.Canceled) {
+			err = ErrClosed
+		}
+		return nil, err
+	}
+
+	buf.buffer = buf.buffer[:copy(buf.buffer[:cap(buf.buffer)], b.buffer[0].buffer)]
+	b.buffer[0] = buf
+	b.isInline = false
+	return buf, nil
+}
+
+// readSource reads from the underlying reader, the errors accepted by OptionWithRetry are retried
+func (b *bufReader) readSource(p []byte) (n int, err error) {
+	for attempt := 1; ; attempt++ {
+		n, err = b.reader.Read(p)
+		if n > 0 {
+			b.reportFirstByte()
+		}
+		if err == nil || errors.Is(err, io.EOF) || !b.shouldRetry(err, attempt) {
+			return
+		}
+		if n > 0 {
+			// deliver the data, the error is retried by the next read
+			return n, nil
+		}
+	}
+}
+
+// resetIdleTimer will postpone the release of OptionWithIdleTimeout, it is no longer needed once the seeker is disabled
+func (b *bufReader) resetIdleTimer() {
+	if b.idleTimer == nil || atomic.LoadInt32(&b.isSeekerDisabled) == 1 {
+		return
+	}
+	b.idleTimer.Reset(b.idleTimeout)
+}
+
+// reportFirstByte will call the cb of OptionWithFirstByteLatency once
+func (b *bufReader) reportFirstByte() {
+	if b.onFirstByte == nil {
+		return
+	}
+	cb := b.onFirstByte
+	b.onFirstByte = nil
+	cb(time.Since(b.createdAt))
+}
+
+func (b *bufReader) shouldRetry(err error, attempt int) bool {
+	if attempt >= b.retryAttempts || atomic.LoadInt32(&b.isClosed) == 1 {
+		return false
+	}
+	return b.isRetryable == nil || b.isRetryable(err)
+}
+
+// limitReadSize limits p to the configured max read size, see OptionWithMaxReadSize
+func (b *bufReader) limitReadSize(p []byte) []byte {
+	if b.maxReadSize > 0 && len(p) > b.maxReadSize {
+		return p[:b.maxReadSize]
+	}
+	return p
+}
+
 func (b *bufReader) getReaderPos() int64 {
 	l := len(b.buffer)
 
@@ -344,14 +1649,115 @@ func (b *bufReader) getReaderPos() int64 {
 		return 0
 	}
 
-	return int64(l-1)*int64(b.pool.BufferSize()) + int64(len(b.buffer[l-1].buffer))
+	return int64(b.bufferBase+l-1)*int64(b.bufSize) + int64(len(b.buffer[l-1].buffer))
+}
+
+// knownSize returns the size of the stream discovered at EOF. Before that, it is computed on demand from the unread
+// bytes reported by the source, so a source partly read before or still growing is reported correctly
+func (b *bufReader) knownSize() (int64, bool) {
+	if b.isSizeKnown {
+		return b.size, true
+	}
+	if b.sizer != nil {
+		return b.getReaderPos() + int64(b.sizer.Len()), true
+	}
+	return 0, false
+}
+
+// bufferOf returns the buffer at the index idx counted from the start of the stream, nil if it is not retained
+func (b *bufReader) bufferOf(idx int) *Buffer {
+	idx -= b.bufferBase
+	if idx < 0 || idx >= len(b.buffer) {
+		return nil
+	}
+	return b.buffer[idx]
+}
+
+// recycleRing will release the oldest buffers behind the current position until at most keep buffers are retained,
+// see OptionWithRingBuffer
+func (b *bufReader) recycleRing(keep int) {
+	if b.ringBuffers <= 0 {
+		return
+	}
+
+	current := b.currentPos / int64(b.bufSize)
+	for b.bufferBase+len(b.buffer)-b.firstRetained > keep && int64(b.firstRetained) < current {
+		b.releaseFirstRetained()
+	}
+}
+
+// releaseFirstRetained will release the oldest retained buffer. The buffer aliased by ReadSlice is dropped
+// instead of being returned to the pool, so the view stays valid
+func (b *bufReader) releaseFirstRetained() {
+	if buf := b.bufferOf(b.firstRetained); buf != nil {
+		if b.firstRetained >= b.slicedBuffers {
+			buf.cleanUp()
+		}
+		b.buffer[b.firstRetained-b.bufferBase] = nil
+	}
+	b.firstRetained++
+}
+
+// getPos returns the current position, it does not need the mu
+func (b *bufReader) getPos() int64 {
+	return atomic.LoadInt64(&b.currentPos)
+}
+
+// getFirstRetainedPos returns the first position still retained, see OptionWithRingBuffer
+func (b *bufReader) getFirstRetainedPos() int64 {
+	return int64(b.firstRetained) * int64(b.bufSize)
+}
+
+// the index math relies on the buffer size snapshot taken when the reader is created
+func (b *bufReader) checkBufferSize() error {
+	if b.pool.BufferSize() != b.bufSize {
+		return ErrBufferSizeChanged
+	}
+	return nil
+}
+
+// disable the seeker and release all buffers once the stream is fully consumed, see OptionWithAutoRelease
+func (b *bufReader) releaseIfDrained() {
+	if !b.autoRelease || !b.isEofReached || b.currentPos < b.getReaderPos() {
+		return
+	}
+	if b.isMarked {
+		// only the data from the mark is kept for Reset
+		for markIdx := int(b.markPos / int64(b.bufSize)); b.firstRetained < markIdx; {
+			b.releaseFirstRetained()
+		}
+		return
+	}
+	if !atomic.CompareAndSwapInt32(&b.isSeekerDisabled, 0, 1) {
+		return
+	}
+	b.reportFillStats()
+	b.cleanUpBuffer(true)
+}
+
+// reportFillStats will call the cb of OptionWithFillStats once
+func (b *bufReader) reportFillStats() {
+	if b.onFillStats == nil {
+		return
+	}
+	cb := b.onFillStats
+	b.onFillStats = nil
+
+	ratios := make([]float64, 0, len(b.buffer))
+	for _, buf := range b.buffer {
+		if buf == nil {
+			continue
+		}
+		ratios = append(ratios, float64(len(buf.buffer))/float64(cap(buf.buffer)))
+	}
+	cb(ratios)
 }
 
 func (b *bufReader) cleanUpBuffer(all bool) {
-	currentReaderPos := int(b.currentPos / int64(b.pool.BufferSize()))
+	currentReaderPos := int(b.currentPos / int64(b.bufSize))
 
 	for i := range b.buffer {
-		if !all && i >= currentReaderPos {
+		if !all && b.bufferBase+i >= currentReaderPos {
 			return
 		}
 
@@ -362,8 +1768,11 @@ func (b *bufReader) cleanUpBuffer(all bool) {
 		b.buffer[i] = nil
 	}
 	b.buffer = nil
+	b.bufferBase = 0
 }
 
+// Close will release the buffers. The underlying reader is closed before waiting for the in-flight Read,
+// so a Read blocked on a source unblocked by its Close returns ErrClosed
 func (b *bufReader) Close() error {
 	if !atomic.CompareAndSwapInt32(&b.isClosed, 0, 1) {
 		return ErrClosed
@@ -372,9 +1781,14 @@ func (b *bufReader) Close() error {
 	defer func() {
 		b.mu.Lock()
 		defer b.mu.Unlock()
+		b.reportFillStats()
 		b.cleanUpBuffer(true)
+		b.tail = nil
 	}()
 
+	if b.idleTimer != nil {
+		b.idleTimer.Stop()
+	}
 	b.cancelCtx()
 	return b.reader.Close()
 }
@@ -2,9 +2,12 @@ package io
 
 import (
 	"bytes"
+	"context"
+	"errors"
 	"io"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 )
@@ -492,6 +495,2195 @@ func TestFlowWithBufferNotFullyFilled(t *testing.T) {
 	}()
 }
 
+func TestFlowBufferSizeChanged(t *testing.T) {
+	mp := &mutableSizePool{bufSize: 5, p: newPool(5)}
+	bf := NewBufferReadSeekCloserFactory(OptionWithPool(mp))
+
+	brsc := bf.NewReader(&testReader{data: []byte("1234567890qwertyuiop")})
+	defer func() {
+		err := brsc.Close()
+		assert.NoError(t, err)
+	}()
+	readBuf := make([]byte, 10)
+
+	n, err := brsc.Read(readBuf[:3])
+	assert.NoError(t, err)
+	assert.EqualValues(t, 3, n)
+	assert.Equal(t, []byte("123"), readBuf[:n])
+
+	mp.SetBufferSize(10)
+
+	n, err = brsc.Read(readBuf[:3])
+	assert.ErrorIs(t, err, ErrBufferSizeChanged)
+	assert.EqualValues(t, 0, n)
+
+	seek, err := brsc.Seek(12, io.SeekStart)
+	assert.ErrorIs(t, err, ErrBufferSizeChanged)
+	assert.EqualValues(t, 3, seek)
+
+	mp.SetBufferSize(5)
+
+	n, err = brsc.Read(readBuf[:3])
+	assert.NoError(t, err)
+	assert.EqualValues(t, 3, n)
+	assert.Equal(t, []byte("456"), readBuf[:n])
+}
+
+func TestReadSeekerReadAt(t *testing.T) {
+	bf := NewBufferReadSeekCloserFactory()
+
+	brsc := bf.NewReader(bytes.NewReader([]byte("1234567890qwertyuiop")))
+	readerAt, ok := brsc.(io.ReaderAt)
+	assert.True(t, ok)
+
+	readBuf := make([]byte, 5)
+	n, err := readerAt.ReadAt(readBuf, 10)
+	assert.NoError(t, err)
+	assert.EqualValues(t, 5, n)
+	assert.Equal(t, []byte("qwert"), readBuf[:n])
+
+	n, err = readerAt.ReadAt(readBuf, 18)
+	assert.ErrorIs(t, err, io.EOF)
+	assert.EqualValues(t, 2, n)
+	assert.Equal(t, []byte("op"), readBuf[:n])
+
+	// ReadAt does not move the current position
+	n, err = brsc.Read(readBuf)
+	assert.NoError(t, err)
+	assert.EqualValues(t, 5, n)
+	assert.Equal(t, []byte("12345"), readBuf[:n])
+
+	err = brsc.Close()
+	assert.NoError(t, err)
+
+	n, err = readerAt.ReadAt(readBuf, 0)
+	assert.ErrorIs(t, err, ErrClosed)
+	assert.EqualValues(t, 0, n)
+
+	brsc = bf.NewReader(&testReadSeekCloser{strings.NewReader("1234567890")})
+	defer brsc.Close()
+	readerAt, ok = brsc.(io.ReaderAt)
+	assert.True(t, ok)
+
+	n, err = readerAt.ReadAt(readBuf, 0)
+	assert.ErrorIs(t, err, ErrReaderAtUnsupported)
+	assert.EqualValues(t, 0, n)
+}
+
+func TestDisableSeekerE(t *testing.T) {
+	tests := []struct {
+		name   string
+		reader io.Reader
+	}{
+		{
+			name:   "reader",
+			reader: &testReader{data: []byte("1234567890")},
+		},
+		{
+			name:   "read seeker",
+			reader: bytes.NewReader([]byte("1234567890")),
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			brsc := NewBufferReadSeekCloserFactory().NewReader(test.reader)
+
+			err := brsc.DisableSeekerE()
+			assert.NoError(t, err)
+
+			err = brsc.DisableSeekerE()
+			assert.ErrorIs(t, err, ErrSeekerDisabled)
+
+			err = brsc.Close()
+			assert.NoError(t, err)
+
+			err = brsc.DisableSeekerE()
+			assert.ErrorIs(t, err, ErrClosed)
+		})
+	}
+
+	brsc := NewBufferReadSeekCloserFactory().NewReader(&testReader{data: []byte("1234567890")})
+	err := brsc.Close()
+	assert.NoError(t, err)
+
+	err = brsc.DisableSeekerE()
+	assert.ErrorIs(t, err, ErrClosed)
+}
+
+func TestFlowAutoRelease(t *testing.T) {
+	tp := &testPool{p: newPool(5)}
+	bf := NewBufferReadSeekCloserFactory(OptionWithPool(tp), OptionWithAutoRelease())
+
+	brsc := bf.NewReader(&testReader{data: []byte("1234567890qwertyuiop")})
+	defer func() {
+		err := brsc.Close()
+		assert.NoError(t, err)
+		assert.EqualValues(t, 0, tp.Diff())
+	}()
+
+	n, err := io.CopyN(Discard, brsc, 12)
+	assert.NoError(t, err)
+	assert.EqualValues(t, 12, n)
+	assert.EqualValues(t, 3, tp.Diff())
+
+	seek, err := brsc.Seek(0, io.SeekStart)
+	assert.NoError(t, err)
+	assert.EqualValues(t, 0, seek)
+
+	n, err = io.Copy(Discard, brsc)
+	assert.NoError(t, err)
+	assert.EqualValues(t, 20, n)
+	assert.EqualValues(t, 0, tp.Diff())
+
+	seek, err = brsc.Seek(0, io.SeekStart)
+	assert.ErrorIs(t, err, ErrSeekerDisabled)
+	assert.EqualValues(t, 20, seek)
+
+	n, err = io.Copy(Discard, brsc)
+	assert.NoError(t, err)
+	assert.EqualValues(t, 0, n)
+	assert.EqualValues(t, 0, tp.Diff())
+}
+
+func TestWriteTo(t *testing.T) {
+	tests := []struct {
+		name   string
+		reader io.Reader
+	}{
+		{
+			name:   "reader",
+			reader: &testReader{data: []byte("1234567890qwertyuiop")},
+		},
+		{
+			name:   "writer to",
+			reader: &testWriterToReader{r: bytes.NewReader([]byte("1234567890qwertyuiop"))},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			tp := &testPool{p: newPool(5)}
+			bf := NewBufferReadSeekCloserFactory(OptionWithPool(tp))
+
+			brsc := bf.NewReader(test.reader)
+			defer func() {
+				err := brsc.Close()
+				assert.NoError(t, err)
+				assert.EqualValues(t, 0, tp.Diff())
+			}()
+
+			n, err := io.CopyN(Discard, brsc, 3)
+			assert.NoError(t, err)
+			assert.EqualValues(t, 3, n)
+
+			buf := &bytes.Buffer{}
+			n, err = io.Copy(buf, brsc)
+			assert.NoError(t, err)
+			assert.EqualValues(t, 17, n)
+			assert.Equal(t, "4567890qwertyuiop", buf.String())
+			assert.EqualValues(t, 5, tp.Diff())
+
+			seek, err := brsc.Seek(7, io.SeekStart)
+			assert.NoError(t, err)
+			assert.EqualValues(t, 7, seek)
+
+			brsc.DisableSeeker()
+			assert.EqualValues(t, 4, tp.Diff())
+
+			buf.Reset()
+			n, err = io.Copy(buf, brsc)
+			assert.NoError(t, err)
+			assert.EqualValues(t, 13, n)
+			assert.Equal(t, "890qwertyuiop", buf.String())
+			assert.EqualValues(t, 0, tp.Diff())
+		})
+	}
+
+	tp := &testPool{p: newPool(5)}
+	bf := NewBufferReadSeekCloserFactory(OptionWithPool(tp))
+	brsc := bf.NewReader(&testWriterToReader{r: bytes.NewReader([]byte("1234567890qwertyuiop"))})
+	defer func() {
+		err := brsc.Close()
+		assert.NoError(t, err)
+		assert.EqualValues(t, 0, tp.Diff())
+	}()
+
+	n, err := io.CopyN(Discard, brsc, 7)
+	assert.NoError(t, err)
+	assert.EqualValues(t, 7, n)
+	assert.EqualValues(t, 2, tp.Diff())
+
+	brsc.DisableSeeker()
+
+	buf := &bytes.Buffer{}
+	n, err = io.Copy(buf, brsc)
+	assert.NoError(t, err)
+	assert.EqualValues(t, 13, n)
+	assert.Equal(t, "890qwertyuiop", buf.String())
+	assert.EqualValues(t, 0, tp.Diff())
+}
+
+func TestFlowMaxReadSize(t *testing.T) {
+	tp := &testPool{p: newPool(5)}
+	bf := NewBufferReadSeekCloserFactory(OptionWithPool(tp), OptionWithMaxReadSize(2))
+
+	source := &testRecordReader{r: &testReader{data: []byte("1234567890qwertyuiop")}}
+	brsc := bf.NewReader(source)
+	defer func() {
+		err := brsc.Close()
+		assert.NoError(t, err)
+		assert.EqualValues(t, 0, tp.Diff())
+	}()
+	readBuf := make([]byte, 10)
+
+	n, err := brsc.Read(readBuf[:7])
+	assert.NoError(t, err)
+	assert.EqualValues(t, 7, n)
+	assert.Equal(t, []byte("1234567"), readBuf[:n])
+	assert.EqualValues(t, 2, tp.Diff())
+
+	seek, err := brsc.Seek(13, io.SeekStart)
+	assert.NoError(t, err)
+	assert.EqualValues(t, 13, seek)
+	assert.EqualValues(t, 3, tp.Diff())
+
+	seek, err = brsc.Seek(-9, io.SeekCurrent)
+	assert.NoError(t, err)
+	assert.EqualValues(t, 4, seek)
+
+	n, err = brsc.Read(readBuf)
+	assert.NoError(t, err)
+	assert.EqualValues(t, 10, n)
+	assert.Equal(t, []byte("567890qwer"), readBuf[:n])
+
+	brsc.DisableSeeker()
+
+	buf := &bytes.Buffer{}
+	written, err := io.Copy(buf, brsc)
+	assert.NoError(t, err)
+	assert.EqualValues(t, 6, written)
+	assert.Equal(t, "tyuiop", buf.String())
+
+	assert.EqualValues(t, 2, source.maxReadLen)
+}
+
+func TestFlowZeroLengthRead(t *testing.T) {
+	tp := &testPool{p: newPool(5)}
+	bf := NewBufferReadSeekCloserFactory(OptionWithPool(tp))
+
+	source := &testReader{data: []byte("1234567890")}
+	brsc := bf.NewReader(source)
+	defer func() {
+		err := brsc.Close()
+		assert.NoError(t, err)
+		assert.EqualValues(t, 0, tp.Diff())
+	}()
+
+	n, err := brsc.Read([]byte{})
+	assert.NoError(t, err)
+	assert.EqualValues(t, 0, n)
+	assert.EqualValues(t, 0, tp.Diff())
+	assert.EqualValues(t, 0, source.pos)
+
+	n, err = brsc.Read(nil)
+	assert.NoError(t, err)
+	assert.EqualValues(t, 0, n)
+	assert.EqualValues(t, 0, tp.Diff())
+	assert.EqualValues(t, 0, source.pos)
+
+	seek, err := brsc.Seek(0, io.SeekCurrent)
+	assert.NoError(t, err)
+	assert.EqualValues(t, 0, seek)
+
+	written, err := io.Copy(Discard, brsc)
+	assert.NoError(t, err)
+	assert.EqualValues(t, 10, written)
+
+	// zero-length read at EOF
+	n, err = brsc.Read([]byte{})
+	assert.NoError(t, err)
+	assert.EqualValues(t, 0, n)
+
+	seek, err = brsc.Seek(0, io.SeekCurrent)
+	assert.NoError(t, err)
+	assert.EqualValues(t, 10, seek)
+}
+
+func TestFlowSeekCurrentPosition(t *testing.T) {
+	tp := &testPool{p: newPool(5)}
+	bf := NewBufferReadSeekCloserFactory(OptionWithPool(tp))
+
+	brsc := bf.NewReader(&testReader{data: []byte("1234567890qwertyuiop")})
+	defer func() {
+		assert.EqualValues(t, 0, tp.Diff())
+	}()
+
+	seek, err := brsc.Seek(0, io.SeekCurrent)
+	assert.NoError(t, err)
+	assert.EqualValues(t, 0, seek)
+	assert.EqualValues(t, 0, tp.Diff())
+
+	n, err := io.CopyN(Discard, brsc, 5)
+	assert.NoError(t, err)
+	assert.EqualValues(t, 5, n)
+	assert.EqualValues(t, 1, tp.Diff())
+
+	seek, err = brsc.Seek(0, io.SeekCurrent)
+	assert.NoError(t, err)
+	assert.EqualValues(t, 5, seek)
+	assert.EqualValues(t, 1, tp.Diff())
+
+	brsc.DisableSeeker()
+
+	seek, err = brsc.Seek(0, io.SeekCurrent)
+	assert.ErrorIs(t, err, ErrSeekerDisabled)
+	assert.EqualValues(t, 5, seek)
+
+	err = brsc.Close()
+	assert.NoError(t, err)
+
+	seek, err = brsc.Seek(0, io.SeekCurrent)
+	assert.ErrorIs(t, err, ErrClosed)
+	assert.EqualValues(t, 5, seek)
+
+	// the position is queried without waiting for the Read blocked on the source
+	pr, pw := io.Pipe()
+	brsc = bf.NewReader(pr)
+	readDone := make(chan struct{})
+	go func() {
+		defer close(readDone)
+		_, _ = brsc.Read(make([]byte, 5))
+	}()
+	time.Sleep(10 * time.Millisecond)
+
+	queried := make(chan int64)
+	go func() {
+		seek, _ := brsc.Seek(0, io.SeekCurrent)
+		queried <- seek
+	}()
+	select {
+	case seek = <-queried:
+		assert.EqualValues(t, 0, seek)
+	case <-time.After(time.Second):
+		t.Error("querying the position should not wait for the Read")
+	}
+
+	_, err = pw.Write([]byte("12345"))
+	assert.NoError(t, err)
+	<-readDone
+	seek, err = brsc.Seek(0, io.SeekCurrent)
+	assert.NoError(t, err)
+	assert.EqualValues(t, 5, seek)
+	assert.NoError(t, brsc.Close())
+}
+
+func TestFlowSourceReadError(t *testing.T) {
+	tp := &testPool{p: newPool(5)}
+	bf := NewBufferReadSeekCloserFactory(OptionWithPool(tp))
+
+	errBroken := errors.New("broken pipe")
+	brsc := bf.NewReader(&testErrReader{data: []byte("1234567890qwertyuiop"), errAt: 12, err: errBroken})
+	defer func() {
+		err := brsc.Close()
+		assert.NoError(t, err)
+		assert.EqualValues(t, 0, tp.Diff())
+	}()
+
+	seek, err := brsc.Seek(10, io.SeekStart)
+	assert.NoError(t, err)
+	assert.EqualValues(t, 10, seek)
+
+	seek, err = brsc.Seek(15, io.SeekStart)
+	assert.ErrorIs(t, err, ErrSourceRead)
+	assert.ErrorIs(t, err, errBroken)
+	assert.NotErrorIs(t, err, ErrSeekerOutOfRange)
+	assert.EqualValues(t, 10, seek)
+
+	seek, err = brsc.Seek(0, io.SeekEnd)
+	assert.ErrorIs(t, err, ErrSourceRead)
+	assert.ErrorIs(t, err, errBroken)
+	assert.EqualValues(t, 10, seek)
+
+	// buffered data is still readable, Read returns the error as is
+	readBuf := make([]byte, 10)
+	n, err := brsc.Read(readBuf)
+	assert.Equal(t, errBroken, err)
+	assert.EqualValues(t, 2, n)
+	assert.Equal(t, []byte("qw"), readBuf[:n])
+
+	// short read is still reported as out of range
+	brsc2 := bf.NewReader(&testReader{data: []byte("1234567890")})
+	defer brsc2.Close()
+
+	seek, err = brsc2.Seek(15, io.SeekStart)
+	assert.ErrorIs(t, err, ErrSeekerOutOfRange)
+	assert.NotErrorIs(t, err, ErrSourceRead)
+	assert.EqualValues(t, 0, seek)
+}
+
+func TestFlowDrainTo(t *testing.T) {
+	tp := &testPool{p: newPool(5)}
+	bf := NewBufferReadSeekCloserFactory(OptionWithPool(tp))
+
+	brsc := bf.NewReader(&testReader{data: []byte("1234567890qwertyuiop")})
+	defer func() {
+		err := brsc.Close()
+		assert.NoError(t, err)
+		assert.EqualValues(t, 0, tp.Diff())
+	}()
+
+	drainer, ok := brsc.(BufferDrainer)
+	assert.True(t, ok)
+
+	seek, err := brsc.Seek(12, io.SeekStart)
+	assert.NoError(t, err)
+	assert.EqualValues(t, 12, seek)
+
+	seek, err = brsc.Seek(4, io.SeekStart)
+	assert.NoError(t, err)
+	assert.EqualValues(t, 4, seek)
+	assert.EqualValues(t, 3, tp.Diff())
+
+	buf := &bytes.Buffer{}
+	n, err := drainer.DrainTo(buf)
+	assert.NoError(t, err)
+	assert.EqualValues(t, 16, n)
+	assert.Equal(t, "567890qwertyuiop", buf.String())
+	assert.EqualValues(t, 0, tp.Diff())
+
+	seek, err = brsc.Seek(0, io.SeekStart)
+	assert.ErrorIs(t, err, ErrSeekerDisabled)
+
+	err = brsc.DisableSeekerE()
+	assert.ErrorIs(t, err, ErrSeekerDisabled)
+
+	buf.Reset()
+	n, err = drainer.DrainTo(buf)
+	assert.NoError(t, err)
+	assert.EqualValues(t, 0, n)
+}
+
+func TestFlowAppend(t *testing.T) {
+	tp := &testPool{p: newPool(5)}
+	bf := NewBufferReadSeekCloserFactory(OptionWithPool(tp))
+
+	brsc := bf.NewReader(&testReader{data: []byte("1234567")})
+	defer func() {
+		err := brsc.Close()
+		assert.NoError(t, err)
+		assert.EqualValues(t, 0, tp.Diff())
+	}()
+
+	appender, ok := brsc.(BufferAppender)
+	assert.True(t, ok)
+
+	err := appender.Append(&testReader{data: []byte("890")})
+	assert.ErrorIs(t, err, ErrSourceNotExhausted)
+
+	seek, err := brsc.Seek(0, io.SeekEnd)
+	assert.NoError(t, err)
+	assert.EqualValues(t, 7, seek)
+
+	err = appender.Append(&testReader{data: []byte("890qwertyuiop")})
+	assert.NoError(t, err)
+
+	// seek across the join boundary
+	seek, err = brsc.Seek(5, io.SeekStart)
+	assert.NoError(t, err)
+	assert.EqualValues(t, 5, seek)
+
+	readBuf := make([]byte, 6)
+	n, err := brsc.Read(readBuf)
+	assert.NoError(t, err)
+	assert.EqualValues(t, 6, n)
+	assert.Equal(t, []byte("67890q"), readBuf[:n])
+
+	seek, err = brsc.Seek(-3, io.SeekEnd)
+	assert.NoError(t, err)
+	assert.EqualValues(t, 17, seek)
+
+	seek, err = brsc.Seek(3, io.SeekStart)
+	assert.NoError(t, err)
+	assert.EqualValues(t, 3, seek)
+
+	buf := &bytes.Buffer{}
+	written, err := io.Copy(buf, brsc)
+	assert.NoError(t, err)
+	assert.EqualValues(t, 17, written)
+	assert.Equal(t, "4567890qwertyuiop", buf.String())
+
+	brsc.DisableSeeker()
+
+	err = appender.Append(&testReader{data: []byte("asdf")})
+	assert.ErrorIs(t, err, ErrSeekerDisabled)
+}
+
+func TestFlowReadCtx(t *testing.T) {
+	tp := &testPool{p: newPool(5)}
+	bf := NewBufferReadSeekCloserFactory(OptionWithPool(tp))
+
+	source := &testSlowReader{r: &testReader{data: []byte("1234567890")}, release: make(chan struct{})}
+	brsc := bf.NewReader(source)
+	defer func() {
+		err := brsc.Close()
+		assert.NoError(t, err)
+		assert.EqualValues(t, 0, tp.Diff())
+	}()
+
+	ctxReader, ok := brsc.(ContextReader)
+	assert.True(t, ok)
+	readBuf := make([]byte, 3)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	n, err := ctxReader.ReadCtx(ctx, readBuf)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.EqualValues(t, 0, n)
+
+	close(source.release)
+
+	seek, err := brsc.Seek(0, io.SeekCurrent)
+	assert.NoError(t, err)
+	assert.EqualValues(t, 0, seek)
+
+	n, err = ctxReader.ReadCtx(context.Background(), readBuf)
+	assert.NoError(t, err)
+	assert.EqualValues(t, 3, n)
+	assert.Equal(t, []byte("123"), readBuf[:n])
+
+	brsc.DisableSeeker()
+
+	buf := &bytes.Buffer{}
+	for {
+		n, err = ctxReader.ReadCtx(context.Background(), readBuf)
+		buf.Write(readBuf[:n])
+		if err != nil {
+			break
+		}
+	}
+	assert.ErrorIs(t, err, io.EOF)
+	assert.Equal(t, "4567890", buf.String())
+}
+
+func TestFlowReadCtxSeekerDisabled(t *testing.T) {
+	tp := &testPool{p: newPool(5)}
+	bf := NewBufferReadSeekCloserFactory(OptionWithPool(tp))
+
+	source := &testSlowReader{r: &testReader{data: []byte("1234567890")}, release: make(chan struct{})}
+	brsc := bf.NewReader(source)
+	defer func() {
+		err := brsc.Close()
+		assert.NoError(t, err)
+		assert.EqualValues(t, 0, tp.Diff())
+	}()
+
+	ctxReader, ok := brsc.(ContextReader)
+	assert.True(t, ok)
+	readBuf := make([]byte, 3)
+
+	brsc.DisableSeeker()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	n, err := ctxReader.ReadCtx(ctx, readBuf)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.EqualValues(t, 0, n)
+
+	close(source.release)
+
+	// the data of the aborted read is not lost
+	buf := &bytes.Buffer{}
+	written, err := io.Copy(buf, brsc)
+	assert.NoError(t, err)
+	assert.EqualValues(t, 10, written)
+	assert.Equal(t, "1234567890", buf.String())
+}
+
+func TestRemaining(t *testing.T) {
+	bf := NewBufferReadSeekCloserFactory(OptionWithSyncPool(5))
+
+	// known immediately
+	brsc := bf.NewReader(bytes.NewReader([]byte("1234567890qwertyuiop")))
+	remainer, ok := brsc.(BufferRemainer)
+	assert.True(t, ok)
+
+	remaining, ok := remainer.Remaining()
+	assert.True(t, ok)
+	assert.EqualValues(t, 20, remaining)
+
+	_, err := brsc.Seek(15, io.SeekStart)
+	assert.NoError(t, err)
+	remaining, ok = remainer.Remaining()
+	assert.True(t, ok)
+	assert.EqualValues(t, 5, remaining)
+
+	err = brsc.Close()
+	assert.NoError(t, err)
+	_, ok = remainer.Remaining()
+	assert.False(t, ok)
+
+	// known after draining
+	brsc = bf.NewReader(&testReader{data: []byte("1234567890qwertyuiop")})
+	defer brsc.Close()
+	remainer, ok = brsc.(BufferRemainer)
+	assert.True(t, ok)
+
+	n, err := io.CopyN(Discard, brsc, 7)
+	assert.NoError(t, err)
+	assert.EqualValues(t, 7, n)
+	_, ok = remainer.Remaining()
+	assert.False(t, ok)
+
+	_, err = brsc.Seek(0, io.SeekEnd)
+	assert.NoError(t, err)
+	_, err = brsc.Seek(7, io.SeekStart)
+	assert.NoError(t, err)
+
+	remaining, ok = remainer.Remaining()
+	assert.True(t, ok)
+	assert.EqualValues(t, 13, remaining)
+
+	brsc.DisableSeeker()
+	_, ok = remainer.Remaining()
+	assert.False(t, ok)
+
+	// known from the size of the source
+	brsc = bf.NewReader(&testSizedReader{testReader{data: []byte("1234567890qwertyuiop")}})
+	defer brsc.Close()
+	remainer = brsc.(BufferRemainer)
+	remaining, ok = remainer.Remaining()
+	assert.True(t, ok)
+	assert.EqualValues(t, 20, remaining)
+
+	n, err = io.CopyN(Discard, brsc, 7)
+	assert.NoError(t, err)
+	assert.EqualValues(t, 7, n)
+	remaining, ok = remainer.Remaining()
+	assert.True(t, ok)
+	assert.EqualValues(t, 13, remaining)
+
+	// the size is computed on demand, so the source read before or growing afterwards is reported correctly
+	source := bytes.NewBufferString("1234567890")
+	source.Next(3)
+	brsc = bf.NewReader(source)
+	defer brsc.Close()
+	remainer = brsc.(BufferRemainer)
+	remaining, ok = remainer.Remaining()
+	assert.True(t, ok)
+	assert.EqualValues(t, 7, remaining)
+
+	n, err = io.CopyN(Discard, brsc, 2)
+	assert.NoError(t, err)
+	assert.EqualValues(t, 2, n)
+	source.WriteString("qwertyuiop")
+	remaining, ok = remainer.Remaining()
+	assert.True(t, ok)
+	assert.EqualValues(t, 15, remaining)
+
+	pos, err := brsc.(FractionSeeker).SeekFraction(1)
+	assert.NoError(t, err)
+	assert.EqualValues(t, 17, pos)
+
+	// sized readers
+	remainer = NewReaderFromReaderAt(strings.NewReader("1234567890"), 10).(BufferRemainer)
+	remaining, ok = remainer.Remaining()
+	assert.True(t, ok)
+	assert.EqualValues(t, 10, remaining)
+}
+
+func TestNewSeekableReader(t *testing.T) {
+	bf := NewBufferReadSeekCloserFactory()
+
+	brsc, err := bf.NewSeekableReader(nil)
+	assert.ErrorIs(t, err, ErrNilReader)
+	assert.Nil(t, brsc)
+
+	var nilReader *testReader
+	brsc, err = bf.NewSeekableReader(nilReader)
+	assert.ErrorIs(t, err, ErrNilReader)
+	assert.Nil(t, brsc)
+
+	brsc, err = bf.NewSeekableReader(&testReader{data: []byte("1234567890")})
+	assert.NoError(t, err)
+	assert.NotNil(t, brsc)
+	defer brsc.Close()
+
+	seek, err := brsc.Seek(-3, io.SeekEnd)
+	assert.NoError(t, err)
+	assert.EqualValues(t, 7, seek)
+}
+
+func TestConstructorInvalidPoolSize(t *testing.T) {
+	tp := &testPool{p: &mutableSizePool{p: newPool(5)}}
+	bf := NewBufferReadSeekCloserFactory(OptionWithPool(tp))
+	assert.EqualValues(t, DefaultBufferSize, bf.BufferSize())
+
+	brsc := bf.NewReader(&testReader{data: []byte("1234567890qwertyuiop")})
+	defer func() {
+		err := brsc.Close()
+		assert.NoError(t, err)
+	}()
+
+	seek, err := brsc.Seek(12, io.SeekStart)
+	assert.NoError(t, err)
+	assert.EqualValues(t, 12, seek)
+
+	readBuf := make([]byte, 3)
+	n, err := brsc.Read(readBuf)
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("ert"), readBuf[:n])
+	assert.EqualValues(t, 0, tp.Diff())
+}
+
+func TestNewReaderNil(t *testing.T) {
+	bf := NewBufferReadSeekCloserFactory()
+
+	var nilReader *testReader
+	for _, r := range []io.Reader{nil, nilReader} {
+		brsc := bf.NewReader(r)
+		assert.NotNil(t, brsc)
+
+		_, err := brsc.Read(make([]byte, 5))
+		assert.ErrorIs(t, err, ErrNilReader)
+
+		_, err = brsc.Seek(0, io.SeekStart)
+		assert.ErrorIs(t, err, ErrNilReader)
+
+		err = brsc.Close()
+		assert.NoError(t, err)
+
+		_, err = brsc.Read(make([]byte, 5))
+		assert.ErrorIs(t, err, ErrClosed)
+	}
+}
+
+func TestFlowCloseDuringRead(t *testing.T) {
+	tests := []struct {
+		name          string
+		disableSeeker bool
+	}{
+		{
+			name: "seeker enabled",
+		},
+		{
+			name:          "seeker disabled",
+			disableSeeker: true,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			tp := &testPool{p: newPool(5)}
+			bf := NewBufferReadSeekCloserFactory(OptionWithPool(tp))
+
+			pr, pw := io.Pipe()
+			defer pw.Close()
+			brsc := bf.NewReader(pr)
+			if test.disableSeeker {
+				brsc.DisableSeeker()
+			}
+
+			readErr := make(chan error, 1)
+			go func() {
+				_, err := brsc.Read(make([]byte, 10))
+				readErr <- err
+			}()
+
+			// the read is stuck, since nothing is written
+			time.Sleep(50 * time.Millisecond)
+
+			err := brsc.Close()
+			assert.NoError(t, err)
+
+			select {
+			case err = <-readErr:
+				assert.ErrorIs(t, err, ErrClosed)
+			case <-time.After(5 * time.Second):
+				t.Fatal("read is not unblocked by close")
+			}
+			assert.EqualValues(t, 0, tp.Diff())
+		})
+	}
+}
+
+func TestFlowInlineThreshold(t *testing.T) {
+	tp := &testPool{p: newPool(10)}
+	bf := NewBufferReadSeekCloserFactory(OptionWithPool(tp), OptionWithInlineThreshold(8))
+
+	// small stream is kept inline, EOF is confirmed by a separate read
+	brsc := bf.NewReader(&testSizedReader{testReader{data: []byte("12345678")}})
+	readBuf := make([]byte, 10)
+
+	n, err := brsc.Read(readBuf)
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("12345678"), readBuf[:n])
+	assert.EqualValues(t, 0, tp.Diff())
+
+	seek, err := brsc.Seek(-2, io.SeekEnd)
+	assert.NoError(t, err)
+	assert.EqualValues(t, 6, seek)
+
+	n, err = brsc.Read(readBuf)
+	assert.Equal(t, []byte("78"), readBuf[:n])
+
+	seek, err = brsc.Seek(1, io.SeekStart)
+	assert.NoError(t, err)
+	assert.EqualValues(t, 1, seek)
+
+	buf := &bytes.Buffer{}
+	_, err = io.Copy(buf, brsc)
+	assert.NoError(t, err)
+	assert.Equal(t, "2345678", buf.String())
+	assert.EqualValues(t, 0, tp.Diff())
+
+	err = brsc.Close()
+	assert.NoError(t, err)
+	assert.EqualValues(t, 0, tp.Diff())
+
+	// small stream not reporting its size is not read inline
+	brsc = bf.NewReader(&testDataEOFReader{data: []byte("12345678"), n: 100})
+	n, err = brsc.Read(readBuf)
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("12345678"), readBuf[:n])
+	assert.EqualValues(t, 1, tp.Diff())
+	err = brsc.Close()
+	assert.NoError(t, err)
+	assert.EqualValues(t, 0, tp.Diff())
+
+	// the first read is not waiting for more than requested from a live stream
+	pr, pw := io.Pipe()
+	brsc = bf.NewReader(pr)
+	go func() {
+		_, _ = pw.Write([]byte("12345"))
+	}()
+	n, err = brsc.Read(readBuf[:5])
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("12345"), readBuf[:n])
+	assert.NoError(t, pw.Close())
+	err = brsc.Close()
+	assert.NoError(t, err)
+	assert.EqualValues(t, 0, tp.Diff())
+
+	// growing stream is moved into the pooled buffers
+	brsc = bf.NewReader(&testGrowingReader{testReader: testReader{data: []byte("1234567890qwe")}, reported: 4})
+	buf.Reset()
+	_, err = io.Copy(buf, brsc)
+	assert.NoError(t, err)
+	assert.Equal(t, "1234567890qwe", buf.String())
+	assert.EqualValues(t, 2, tp.Diff())
+	err = brsc.Close()
+	assert.NoError(t, err)
+	assert.EqualValues(t, 0, tp.Diff())
+
+	// larger stream is read into the pooled buffers
+	brsc = bf.NewReader(&testSizedReader{testReader{data: []byte("1234567890qwertyuiop")}})
+
+	n, err = brsc.Read(readBuf[:3])
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("123"), readBuf[:n])
+	assert.EqualValues(t, 1, tp.Diff())
+
+	seek, err = brsc.Seek(12, io.SeekStart)
+	assert.NoError(t, err)
+	assert.EqualValues(t, 12, seek)
+	assert.EqualValues(t, 2, tp.Diff())
+
+	seek, err = brsc.Seek(7, io.SeekStart)
+	assert.NoError(t, err)
+	assert.EqualValues(t, 7, seek)
+
+	buf.Reset()
+	_, err = io.Copy(buf, brsc)
+	assert.NoError(t, err)
+	assert.Equal(t, "890qwertyuiop", buf.String())
+
+	err = brsc.Close()
+	assert.NoError(t, err)
+	assert.EqualValues(t, 0, tp.Diff())
+}
+
+func TestSeekTracked(t *testing.T) {
+	tp := &testPool{p: newPool(5)}
+	bf := NewBufferReadSeekCloserFactory(OptionWithPool(tp))
+
+	brsc := bf.NewReader(&testReader{data: []byte("1234567890qwertyuiop")})
+	defer func() {
+		err := brsc.Close()
+		assert.NoError(t, err)
+		assert.EqualValues(t, 0, tp.Diff())
+	}()
+	tracker, ok := brsc.(SeekTracker)
+	assert.True(t, ok)
+
+	// forward seek pulls new data from the source
+	pos, buffered, err := tracker.SeekTracked(12, io.SeekStart)
+	assert.NoError(t, err)
+	assert.EqualValues(t, 12, pos)
+	assert.EqualValues(t, 15, buffered)
+	assert.EqualValues(t, 3, tp.Diff())
+
+	// backward seek is served by the buffer
+	pos, buffered, err = tracker.SeekTracked(-10, io.SeekCurrent)
+	assert.NoError(t, err)
+	assert.EqualValues(t, 2, pos)
+	assert.EqualValues(t, 0, buffered)
+	assert.EqualValues(t, 3, tp.Diff())
+
+	pos, buffered, err = tracker.SeekTracked(0, io.SeekEnd)
+	assert.NoError(t, err)
+	assert.EqualValues(t, 20, pos)
+	assert.EqualValues(t, 5, buffered)
+
+	brsc.DisableSeeker()
+	_, buffered, err = tracker.SeekTracked(0, io.SeekStart)
+	assert.ErrorIs(t, err, ErrSeekerDisabled)
+	assert.EqualValues(t, 0, buffered)
+}
+
+func TestDisableSeekerFlush(t *testing.T) {
+	tp := &testPool{p: newPool(5)}
+	bf := NewBufferReadSeekCloserFactory(OptionWithPool(tp))
+
+	brsc := bf.NewReader(&testReader{data: []byte("1234567890qwertyuiop")})
+	defer func() {
+		err := brsc.Close()
+		assert.NoError(t, err)
+		assert.EqualValues(t, 0, tp.Diff())
+	}()
+	flusher, ok := brsc.(BufferFlusher)
+	assert.True(t, ok)
+
+	_, err := brsc.Seek(12, io.SeekStart)
+	assert.NoError(t, err)
+	_, err = brsc.Seek(3, io.SeekStart)
+	assert.NoError(t, err)
+	assert.EqualValues(t, 3, tp.Diff())
+
+	flushed := &bytes.Buffer{}
+	err = flusher.DisableSeekerFlush(flushed)
+	assert.NoError(t, err)
+	assert.Equal(t, "4567890qwert", flushed.String())
+	assert.EqualValues(t, 0, tp.Diff())
+
+	_, err = brsc.Seek(0, io.SeekStart)
+	assert.ErrorIs(t, err, ErrSeekerDisabled)
+
+	rest := &bytes.Buffer{}
+	_, err = io.Copy(rest, brsc)
+	assert.NoError(t, err)
+	assert.Equal(t, "yuiop", rest.String())
+	assert.Equal(t, "4567890qwertyuiop", flushed.String()+rest.String())
+
+	err = flusher.DisableSeekerFlush(flushed)
+	assert.ErrorIs(t, err, ErrSeekerDisabled)
+}
+
+func TestNewReaderWithBufferSize(t *testing.T) {
+	tp := &testPool{p: newPool(5)}
+	bf := NewBufferReadSeekCloserFactory(OptionWithPool(tp))
+
+	// same size as the factory's pool
+	brsc := bf.NewReaderWithBufferSize(&testReader{data: []byte("1234567890qwertyuiop")}, 5)
+	assert.EqualValues(t, 5, brsc.(*bufReader).bufSize)
+
+	_, err := brsc.Seek(12, io.SeekStart)
+	assert.NoError(t, err)
+	assert.EqualValues(t, 3, tp.Diff())
+
+	err = brsc.Close()
+	assert.NoError(t, err)
+	assert.EqualValues(t, 0, tp.Diff())
+
+	// different size is using its own pool
+	brsc = bf.NewReaderWithBufferSize(&testReader{data: []byte("1234567890qwertyuiop")}, 8)
+	defer func() {
+		err := brsc.Close()
+		assert.NoError(t, err)
+	}()
+	assert.EqualValues(t, 8, brsc.(*bufReader).bufSize)
+
+	_, err = brsc.Seek(12, io.SeekStart)
+	assert.NoError(t, err)
+	assert.EqualValues(t, 2, len(brsc.(*bufReader).buffer))
+	assert.EqualValues(t, 0, tp.Diff())
+
+	_, err = brsc.Seek(-5, io.SeekCurrent)
+	assert.NoError(t, err)
+
+	buf := &bytes.Buffer{}
+	_, err = io.Copy(buf, brsc)
+	assert.NoError(t, err)
+	assert.Equal(t, "890qwertyuiop", buf.String())
+	assert.EqualValues(t, 8, bf.(*bufferReadSeekCloserFactory).sizedPool(8).BufferSize())
+}
+
+func TestMarkReset(t *testing.T) {
+	tp := &testPool{p: newPool(5)}
+	bf := NewBufferReadSeekCloserFactory(OptionWithPool(tp), OptionWithAutoRelease())
+
+	brsc := bf.NewReader(&testReader{data: []byte("1234567890qwertyuiop")})
+	defer func() {
+		err := brsc.Close()
+		assert.NoError(t, err)
+		assert.EqualValues(t, 0, tp.Diff())
+	}()
+	marker, ok := brsc.(BufferMarker)
+	assert.True(t, ok)
+
+	_, err := brsc.Seek(5, io.SeekStart)
+	assert.NoError(t, err)
+
+	token, err := marker.Mark()
+	assert.NoError(t, err)
+	assert.EqualValues(t, 5, token)
+
+	// fully consumed, but the marked data is not auto released
+	buf := &bytes.Buffer{}
+	_, err = io.Copy(buf, brsc)
+	assert.NoError(t, err)
+	assert.Equal(t, "67890qwertyuiop", buf.String())
+
+	err = marker.Reset(token)
+	assert.NoError(t, err)
+
+	readBuf := make([]byte, 5)
+	n, err := brsc.Read(readBuf)
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("67890"), readBuf[:n])
+
+	err = marker.Reset(21)
+	assert.ErrorIs(t, err, ErrSeekerOutOfRange)
+
+	brsc.DisableSeeker()
+	err = marker.Reset(token)
+	assert.ErrorIs(t, err, ErrMarkReleased)
+
+	_, err = marker.Mark()
+	assert.ErrorIs(t, err, ErrSeekerDisabled)
+}
+
+func TestMarkAutoRelease(t *testing.T) {
+	pool, outstanding := NewTrackingPool(4)
+	bf := NewBufferReadSeekCloserFactory(OptionWithPool(pool), OptionWithAutoRelease())
+	data := []byte("1234567890qwertyuiop")
+
+	// the mark is consumed by Reset
+	brsc := bf.NewReader(&testReader{data: data})
+	marker := brsc.(BufferMarker)
+	token, err := marker.Mark()
+	assert.NoError(t, err)
+	_, err = io.CopyN(Discard, brsc, 5)
+	assert.NoError(t, err)
+	assert.NoError(t, marker.Reset(token))
+
+	buf := &bytes.Buffer{}
+	_, err = io.Copy(buf, brsc)
+	assert.NoError(t, err)
+	assert.Equal(t, string(data), buf.String())
+	assert.EqualValues(t, 0, outstanding())
+	assert.ErrorIs(t, marker.Reset(token), ErrMarkReleased)
+	assert.NoError(t, brsc.Close())
+
+	// only the data from the mark is pinned
+	brsc = bf.NewReader(&testReader{data: data})
+	marker = brsc.(BufferMarker)
+	_, err = brsc.Seek(13, io.SeekStart)
+	assert.NoError(t, err)
+	token, err = marker.Mark()
+	assert.NoError(t, err)
+	_, err = io.Copy(Discard, brsc)
+	assert.NoError(t, err)
+	// the buffers from the one holding the mark, including the one allocated before EOF is reached
+	assert.EqualValues(t, 3, outstanding())
+
+	_, err = brsc.Seek(5, io.SeekStart)
+	assert.ErrorIs(t, err, ErrSeekerOutOfRange)
+	assert.NoError(t, marker.Reset(token))
+	buf.Reset()
+	_, err = io.Copy(buf, brsc)
+	assert.NoError(t, err)
+	assert.Equal(t, "rtyuiop", buf.String())
+	assert.EqualValues(t, 0, outstanding())
+	assert.NoError(t, brsc.Close())
+}
+
+func TestMemUsage(t *testing.T) {
+	tp := &testPool{p: newPool(5)}
+	bf := NewBufferReadSeekCloserFactory(OptionWithPool(tp))
+
+	brsc := bf.NewReader(&testReader{data: []byte("1234567890qwertyuiop")})
+	reporter, ok := brsc.(MemoryReporter)
+	assert.True(t, ok)
+	assert.EqualValues(t, 0, reporter.MemUsage())
+
+	_, err := brsc.Seek(3, io.SeekStart)
+	assert.NoError(t, err)
+	assert.EqualValues(t, 5, reporter.MemUsage())
+
+	_, err = brsc.Seek(15, io.SeekStart)
+	assert.NoError(t, err)
+	assert.EqualValues(t, 15, reporter.MemUsage())
+
+	_, err = brsc.Seek(2, io.SeekStart)
+	assert.NoError(t, err)
+	assert.EqualValues(t, 15, reporter.MemUsage())
+
+	_, err = brsc.Seek(15, io.SeekStart)
+	assert.NoError(t, err)
+	brsc.DisableSeeker()
+	assert.EqualValues(t, 0, reporter.MemUsage())
+	assert.EqualValues(t, 0, tp.Diff())
+
+	// the remaining buffer is released by Close
+	brsc = bf.NewReader(&testReader{data: []byte("1234567890qwertyuiop")})
+	reporter = brsc.(MemoryReporter)
+	_, err = brsc.Seek(12, io.SeekStart)
+	assert.NoError(t, err)
+	assert.EqualValues(t, 15, reporter.MemUsage())
+
+	err = brsc.Close()
+	assert.NoError(t, err)
+	assert.EqualValues(t, 0, reporter.MemUsage())
+	assert.EqualValues(t, 0, tp.Diff())
+}
+
+func TestFlowRedundantSeek(t *testing.T) {
+	tp := &testPool{p: newPool(5)}
+	bf := NewBufferReadSeekCloserFactory(OptionWithPool(tp))
+
+	brsc := bf.NewReader(&testReader{data: []byte("1234567890qwertyuiop")})
+	defer func() {
+		err := brsc.Close()
+		assert.NoError(t, err)
+		assert.EqualValues(t, 0, tp.Diff())
+	}()
+
+	// the buffer is fully filled, the next byte is not buffered yet
+	n, err := brsc.Read(make([]byte, 5))
+	assert.NoError(t, err)
+	assert.EqualValues(t, 5, n)
+	assert.EqualValues(t, 1, tp.Diff())
+
+	seek, err := brsc.Seek(5, io.SeekStart)
+	assert.NoError(t, err)
+	assert.EqualValues(t, 5, seek)
+	assert.EqualValues(t, 1, tp.Diff())
+
+	seek, err = brsc.Seek(0, io.SeekCurrent)
+	assert.NoError(t, err)
+	assert.EqualValues(t, 5, seek)
+	assert.EqualValues(t, 1, tp.Diff())
+
+	seek, err = brsc.Seek(0, io.SeekEnd)
+	assert.NoError(t, err)
+	assert.EqualValues(t, 20, seek)
+	assert.EqualValues(t, 5, tp.Diff())
+
+	// the end is known, so nothing is read
+	seek, err = brsc.Seek(0, io.SeekEnd)
+	assert.NoError(t, err)
+	assert.EqualValues(t, 20, seek)
+	assert.EqualValues(t, 5, tp.Diff())
+
+	// the end of the sized source is known before EOF, so only the data up to the target is read
+	sized := bf.NewReader(&testSizedReader{testReader{data: []byte("1234567890qwertyuiop")}})
+	seek, buffered, err := sized.(SeekTracker).SeekTracked(-5, io.SeekEnd)
+	assert.NoError(t, err)
+	assert.EqualValues(t, 15, seek)
+	assert.EqualValues(t, 15, buffered)
+	assert.NoError(t, sized.Close())
+}
+
+func TestFlowRetry(t *testing.T) {
+	errTransient := errors.New("transient")
+	errFatal := errors.New("fatal")
+	isRetryable := func(err error) bool {
+		return errors.Is(err, errTransient)
+	}
+
+	tp := &testPool{p: newPool(5)}
+	bf := NewBufferReadSeekCloserFactory(OptionWithPool(tp), OptionWithRetry(3, isRetryable))
+
+	// fails once then succeeds
+	brsc := bf.NewReader(&testFlakyReader{r: &testReader{data: []byte("1234567890qwertyuiop")}, failures: 1, err: errTransient})
+	buf := &bytes.Buffer{}
+	_, err := io.Copy(buf, brsc)
+	assert.NoError(t, err)
+	assert.Equal(t, "1234567890qwertyuiop", buf.String())
+	err = brsc.Close()
+	assert.NoError(t, err)
+
+	// the attempts are exhausted
+	brsc = bf.NewReader(&testFlakyReader{r: &testReader{data: []byte("1234567890qwertyuiop")}, failures: 3, err: errTransient})
+	_, err = brsc.Read(make([]byte, 5))
+	assert.Equal(t, errTransient, err)
+	err = brsc.Close()
+	assert.NoError(t, err)
+
+	// the error is not retryable
+	brsc = bf.NewReader(&testFlakyReader{r: &testReader{data: []byte("1234567890qwertyuiop")}, failures: 1, err: errFatal})
+	_, err = brsc.Read(make([]byte, 5))
+	assert.ErrorIs(t, err, errFatal)
+	err = brsc.Close()
+	assert.NoError(t, err)
+	assert.EqualValues(t, 0, tp.Diff())
+}
+
+func TestFlowSeekEndSizedSource(t *testing.T) {
+	tp := &testPool{p: newPool(5)}
+	bf := NewBufferReadSeekCloserFactory(OptionWithPool(tp))
+
+	brsc := bf.NewReader(&testSizedReader{testReader{data: []byte("1234567890qwertyuiop")}})
+	defer func() {
+		err := brsc.Close()
+		assert.NoError(t, err)
+		assert.EqualValues(t, 0, tp.Diff())
+	}()
+
+	// only the bytes until the resulting position are buffered
+	seek, err := brsc.Seek(-12, io.SeekEnd)
+	assert.NoError(t, err)
+	assert.EqualValues(t, 8, seek)
+	assert.EqualValues(t, 2, tp.Diff())
+
+	readBuf := make([]byte, 4)
+	n, err := brsc.Read(readBuf)
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("90qw"), readBuf[:n])
+
+	seek, err = brsc.Seek(0, io.SeekEnd)
+	assert.NoError(t, err)
+	assert.EqualValues(t, 20, seek)
+
+	_, err = brsc.Seek(-21, io.SeekEnd)
+	assert.ErrorIs(t, err, ErrSeekerOutOfRange)
+}
+
+func TestReadSlice(t *testing.T) {
+	tp := &testPool{p: newPool(5)}
+	bf := NewBufferReadSeekCloserFactory(OptionWithPool(tp))
+
+	brsc := bf.NewReader(&testReader{data: []byte("1234567890qwertyuiop")})
+	defer func() {
+		err := brsc.Close()
+		assert.NoError(t, err)
+		assert.EqualValues(t, 0, tp.Diff())
+	}()
+	slicer, ok := brsc.(SliceReader)
+	assert.True(t, ok)
+
+	view, err := slicer.ReadSlice(3)
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("123"), view)
+
+	// the view does not cross the buffer boundary
+	view, err = slicer.ReadSlice(10)
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("45"), view)
+
+	view, err = slicer.ReadSlice(10)
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("67890"), view)
+
+	_, err = brsc.Seek(-2, io.SeekEnd)
+	assert.NoError(t, err)
+
+	view, err = slicer.ReadSlice(10)
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("op"), view)
+
+	_, err = slicer.ReadSlice(10)
+	assert.ErrorIs(t, err, io.EOF)
+
+	// the view aliases the buffer
+	_, err = brsc.Seek(0, io.SeekStart)
+	assert.NoError(t, err)
+	view, err = slicer.ReadSlice(5)
+	assert.NoError(t, err)
+	readBuf := make([]byte, 5)
+	_, err = brsc.Seek(0, io.SeekStart)
+	assert.NoError(t, err)
+	_, err = brsc.Read(readBuf)
+	assert.NoError(t, err)
+	assert.Equal(t, readBuf, view)
+	assert.Equal(t, 5, cap(view))
+
+	brsc.DisableSeeker()
+	_, err = slicer.ReadSlice(5)
+	assert.ErrorIs(t, err, ErrSeekerDisabled)
+}
+
+func TestFlowDataWithEOF(t *testing.T) {
+	tests := []struct {
+		name          string
+		disableSeeker bool
+	}{
+		{
+			name: "seeker enabled",
+		},
+		{
+			name:          "seeker disabled",
+			disableSeeker: true,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			tp := &testPool{p: newPool(10)}
+			bf := NewBufferReadSeekCloserFactory(OptionWithPool(tp))
+
+			// the last call returns (5, io.EOF)
+			brsc := bf.NewReader(&testDataEOFReader{data: []byte("1234567890qwert"), n: 5})
+			defer func() {
+				err := brsc.Close()
+				assert.NoError(t, err)
+				assert.EqualValues(t, 0, tp.Diff())
+			}()
+			if test.disableSeeker {
+				brsc.DisableSeeker()
+			}
+			readBuf := make([]byte, 10)
+
+			n, err := io.ReadFull(brsc, readBuf)
+			assert.NoError(t, err)
+			assert.Equal(t, []byte("1234567890"), readBuf[:n])
+
+			n, err = brsc.Read(readBuf)
+			assert.EqualValues(t, 5, n)
+			assert.Equal(t, []byte("qwert"), readBuf[:n])
+			if test.disableSeeker {
+				// the source is read directly, returning the data along with EOF is allowed by io.Reader
+				assert.ErrorIs(t, err, io.EOF)
+			} else {
+				// EOF is deferred to the next read
+				assert.NoError(t, err)
+			}
+
+			n, err = brsc.Read(readBuf)
+			assert.EqualValues(t, 0, n)
+			assert.ErrorIs(t, err, io.EOF)
+		})
+	}
+}
+
+func TestForwardOnly(t *testing.T) {
+	tp := &testPool{p: newPool(5)}
+	bf := NewBufferReadSeekCloserFactory(OptionWithPool(tp))
+
+	brsc := bf.NewReader(&testReader{data: []byte("1234567890qwertyuiop")})
+	viewer, ok := brsc.(ForwardOnlyViewer)
+	assert.True(t, ok)
+
+	n, err := brsc.Read(make([]byte, 3))
+	assert.NoError(t, err)
+	assert.EqualValues(t, 3, n)
+
+	view := viewer.ForwardOnly()
+	_, ok = view.(io.Seeker)
+	assert.False(t, ok)
+	_, ok = view.(io.WriterTo)
+	assert.False(t, ok)
+
+	// the view shares the position
+	readBuf := make([]byte, 5)
+	n, err = view.Read(readBuf)
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("45678"), readBuf[:n])
+
+	// the buffered data is still seekable from the reader
+	seek, err := brsc.Seek(0, io.SeekStart)
+	assert.NoError(t, err)
+	assert.EqualValues(t, 0, seek)
+
+	buf := &bytes.Buffer{}
+	_, err = io.Copy(buf, view)
+	assert.NoError(t, err)
+	assert.Equal(t, "1234567890qwertyuiop", buf.String())
+
+	err = view.Close()
+	assert.NoError(t, err)
+	assert.EqualValues(t, 0, tp.Diff())
+
+	_, err = brsc.Read(readBuf)
+	assert.ErrorIs(t, err, ErrClosed)
+}
+
+func TestFillStats(t *testing.T) {
+	var reported [][]float64
+	tp := &testPool{p: newPool(10)}
+	bf := NewBufferReadSeekCloserFactory(OptionWithPool(tp), OptionWithFillStats(func(ratios []float64) {
+		reported = append(reported, ratios)
+	}))
+
+	// the single buffer is partially filled
+	brsc := bf.NewReader(&testReader{data: []byte("1234")})
+	_, err := brsc.Seek(0, io.SeekEnd)
+	assert.NoError(t, err)
+
+	err = brsc.Close()
+	assert.NoError(t, err)
+	assert.Equal(t, [][]float64{{0.4}}, reported)
+
+	// reported once by DisableSeeker
+	reported = nil
+	brsc = bf.NewReader(&testReader{data: []byte("1234567890qwertyuiop")})
+	_, err = brsc.Seek(15, io.SeekStart)
+	assert.NoError(t, err)
+	brsc.DisableSeeker()
+
+	err = brsc.Close()
+	assert.NoError(t, err)
+	assert.Equal(t, [][]float64{{1, 1}}, reported)
+	assert.EqualValues(t, 0, tp.Diff())
+}
+
+func TestFlowSeekEndCached(t *testing.T) {
+	tp := &testPool{p: newPool(5)}
+	bf := NewBufferReadSeekCloserFactory(OptionWithPool(tp))
+
+	source := &testRecordReader{r: &testReader{data: []byte("1234567890qwertyuiop")}}
+	brsc := bf.NewReader(source)
+	defer func() {
+		err := brsc.Close()
+		assert.NoError(t, err)
+		assert.EqualValues(t, 0, tp.Diff())
+	}()
+
+	seek, err := brsc.Seek(-5, io.SeekEnd)
+	assert.NoError(t, err)
+	assert.EqualValues(t, 15, seek)
+	diff := tp.Diff()
+
+	// the end is cached, so the source is not read anymore
+	source.r = nil
+	seek, err = brsc.Seek(-10, io.SeekEnd)
+	assert.NoError(t, err)
+	assert.EqualValues(t, 10, seek)
+	assert.EqualValues(t, diff, tp.Diff())
+
+	seek, err = brsc.Seek(0, io.SeekEnd)
+	assert.NoError(t, err)
+	assert.EqualValues(t, 20, seek)
+	assert.EqualValues(t, diff, tp.Diff())
+}
+
+func TestSourceKind(t *testing.T) {
+	bf := NewBufferReadSeekCloserFactory()
+	tests := []struct {
+		name   string
+		reader io.Reader
+		kind   string
+	}{
+		{
+			name:   "reader",
+			reader: &testReader{data: []byte("1234567890qwertyuiop")},
+			kind:   SourceKindReader,
+		},
+		{
+			name:   "read closer",
+			reader: NopCloser(&testReader{data: []byte("1234567890qwertyuiop")}),
+			kind:   SourceKindReadCloser,
+		},
+		{
+			name:   "strings reader",
+			reader: strings.NewReader("1234567890qwertyuiop"),
+			kind:   SourceKindReadSeeker,
+		},
+		{
+			name:   "strings read closer",
+			reader: &testReadSeekCloser{strings.NewReader("1234567890qwertyuiop")},
+			kind:   SourceKindReadSeeker,
+		},
+		{
+			name:   "bytes reader",
+			reader: bytes.NewReader([]byte("1234567890qwertyuiop")),
+			kind:   SourceKindReadSeeker,
+		},
+		{
+			name:   "wrapped BRSC",
+			reader: bf.NewReader(bytes.NewReader([]byte("1234567890"))),
+			kind:   SourceKindBufferReadSeekCloser,
+		},
+		{
+			name:   "nil",
+			reader: nil,
+			kind:   SourceKindNil,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			brsc := bf.NewReader(test.reader)
+			assert.Equal(t, test.kind, brsc.SourceKind())
+			assert.NoError(t, brsc.Close())
+		})
+	}
+}
+
+func TestDisableSeekerKeepTail(t *testing.T) {
+	tp := &testPool{p: newPool(4)}
+	bf := NewBufferReadSeekCloserFactory(OptionWithPool(tp))
+	brsc := bf.NewReader(&testReader{data: []byte("1234567890qwertyuiop")})
+	tk, ok := brsc.(TailKeeper)
+	assert.True(t, ok)
+
+	buf := make([]byte, 6)
+	n, err := io.ReadFull(brsc, buf)
+	assert.NoError(t, err)
+	assert.Equal(t, "123456", string(buf[:n]))
+
+	// the unread bytes are served from the buffers while the seeker is enabled
+	assert.NoError(t, tk.UnreadBytes(2))
+	n, err = io.ReadFull(brsc, buf[:2])
+	assert.NoError(t, err)
+	assert.Equal(t, "56", string(buf[:n]))
+
+	assert.NoError(t, tk.DisableSeekerKeepTail(3))
+	assert.ErrorIs(t, tk.DisableSeekerKeepTail(3), ErrSeekerDisabled)
+
+	// the tail is seeded by the data read before the seeker is disabled
+	assert.NoError(t, tk.UnreadBytes(3))
+	n, err = io.ReadFull(brsc, buf)
+	assert.NoError(t, err)
+	assert.Equal(t, "456789", string(buf[:n]))
+
+	// peek back within the tail window
+	assert.NoError(t, tk.UnreadBytes(2))
+	n, err = io.ReadFull(brsc, buf[:4])
+	assert.NoError(t, err)
+	assert.Equal(t, "890q", string(buf[:n]))
+
+	// beyond the tail
+	assert.ErrorIs(t, tk.UnreadBytes(4), ErrSeekerDisabled)
+	_, err = brsc.Seek(-1, io.SeekCurrent)
+	assert.ErrorIs(t, err, ErrSeekerDisabled)
+
+	assert.NoError(t, tk.UnreadBytes(3))
+	rest := &bytes.Buffer{}
+	_, err = io.Copy(rest, brsc)
+	assert.NoError(t, err)
+	assert.Equal(t, "90qwertyuiop", rest.String())
+
+	assert.NoError(t, brsc.Close())
+	assert.EqualValues(t, 0, tp.Diff())
+}
+
+func TestFactoryConfig(t *testing.T) {
+	assert.Equal(t, FactoryConfig{
+		BufferSize: DefaultBufferSize,
+		PoolType:   "*io.pool",
+	}, NewBufferReadSeekCloserFactory().Config())
+
+	bf := NewBufferReadSeekCloserFactory(
+		OptionWithPool(&testPool{p: newPool(16)}),
+		OptionWithAutoRelease(),
+		OptionWithMaxReadSize(4),
+		OptionWithInlineThreshold(8),
+		OptionWithRetry(3, nil),
+		OptionWithFillStats(func(ratios []float64) {}),
+	)
+	assert.Equal(t, FactoryConfig{
+		BufferSize:      16,
+		PoolType:        "*io.testPool",
+		AutoRelease:     true,
+		MaxReadSize:     4,
+		InlineThreshold: 8,
+		RetryAttempts:   3,
+		FillStats:       true,
+	}, bf.Config())
+}
+
+func TestSeekBuffered(t *testing.T) {
+	tp := &testPool{p: newPool(5)}
+	bf := NewBufferReadSeekCloserFactory(OptionWithPool(tp))
+	src := &testReader{data: []byte("1234567890qwertyuiop")}
+	brsc := bf.NewReader(src)
+	bs, ok := brsc.(BufferedSeeker)
+	assert.True(t, ok)
+
+	readBuf := make([]byte, 8)
+	n, err := io.ReadFull(brsc, readBuf)
+	assert.NoError(t, err)
+	assert.EqualValues(t, 8, n)
+
+	// in-buffer seek
+	pos, err := bs.SeekBuffered(2, io.SeekStart)
+	assert.NoError(t, err)
+	assert.EqualValues(t, 2, pos)
+	n, err = brsc.Read(readBuf[:3])
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("345"), readBuf[:n])
+
+	pos, err = bs.SeekBuffered(3, io.SeekCurrent)
+	assert.NoError(t, err)
+	assert.EqualValues(t, 8, pos)
+
+	// past-buffer seek does not read from the source
+	srcPos := src.pos
+	pos, err = bs.SeekBuffered(12, io.SeekStart)
+	assert.ErrorIs(t, err, ErrNotBuffered)
+	assert.EqualValues(t, 8, pos)
+	_, err = bs.SeekBuffered(-1, io.SeekEnd)
+	assert.ErrorIs(t, err, ErrNotBuffered)
+	assert.Equal(t, srcPos, src.pos)
+
+	_, err = bs.SeekBuffered(-1, io.SeekStart)
+	assert.ErrorIs(t, err, ErrSeekerOutOfRange)
+
+	// the end is known once the source reaches EOF
+	_, err = brsc.Seek(0, io.SeekEnd)
+	assert.NoError(t, err)
+	pos, err = bs.SeekBuffered(-4, io.SeekEnd)
+	assert.NoError(t, err)
+	assert.EqualValues(t, 16, pos)
+	_, err = bs.SeekBuffered(21, io.SeekStart)
+	assert.ErrorIs(t, err, ErrSeekerOutOfRange)
+
+	assert.NoError(t, brsc.Close())
+	assert.EqualValues(t, 0, tp.Diff())
+}
+
+func TestRingBuffer(t *testing.T) {
+	tp := &testPool{p: newPool(4)}
+	bf := NewBufferReadSeekCloserFactory(OptionWithPool(tp), OptionWithRingBuffer(2))
+	brsc := bf.NewReader(&testReader{data: []byte("1234567890qwertyuiop")})
+
+	readBuf := make([]byte, 4)
+	for i := 0; i < 4; i++ {
+		n, err := io.ReadFull(brsc, readBuf)
+		assert.NoError(t, err)
+		assert.EqualValues(t, 4, n)
+	}
+	assert.Equal(t, []byte("erty"), readBuf)
+	assert.EqualValues(t, 8, brsc.(MemoryReporter).MemUsage())
+
+	// before the oldest retained buffer
+	_, err := brsc.Seek(7, io.SeekStart)
+	assert.ErrorIs(t, err, ErrSeekerOutOfRange)
+	_, err = brsc.Seek(-9, io.SeekCurrent)
+	assert.ErrorIs(t, err, ErrSeekerOutOfRange)
+
+	// within the window
+	pos, err := brsc.Seek(-6, io.SeekCurrent)
+	assert.NoError(t, err)
+	assert.EqualValues(t, 10, pos)
+	n, err := io.ReadFull(brsc, readBuf)
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("qwer"), readBuf[:n])
+
+	assert.NoError(t, brsc.Close())
+	assert.EqualValues(t, 0, tp.Diff())
+}
+
+func TestRingBufferBounded(t *testing.T) {
+	pool, outstanding := NewTrackingPool(4)
+	bf := NewBufferReadSeekCloserFactory(OptionWithPool(pool), OptionWithRingBuffer(2))
+	data := make([]byte, 4000)
+	for i := range data {
+		data[i] = byte(i)
+	}
+
+	// a forward seek only keeps the buffers behind its target
+	brsc := bf.NewReader(&testReader{data: data})
+	pos, err := brsc.Seek(3000, io.SeekStart)
+	assert.NoError(t, err)
+	assert.EqualValues(t, 3000, pos)
+	assert.LessOrEqual(t, outstanding(), int32(2))
+	readBuf := make([]byte, 4)
+	_, err = io.ReadFull(brsc, readBuf)
+	assert.NoError(t, err)
+	assert.Equal(t, data[3000:3004], readBuf)
+
+	// so does a large read
+	readBuf = make([]byte, 900)
+	_, err = io.ReadFull(brsc, readBuf)
+	assert.NoError(t, err)
+	assert.Equal(t, data[3004:3904], readBuf)
+	assert.LessOrEqual(t, outstanding(), int32(2))
+
+	// the failed seek leaves the position unchanged
+	pos, err = brsc.Seek(5000, io.SeekStart)
+	assert.ErrorIs(t, err, ErrSeekerOutOfRange)
+	assert.EqualValues(t, 3904, pos)
+	pos, err = brsc.Seek(0, io.SeekCurrent)
+	assert.NoError(t, err)
+	assert.EqualValues(t, 3904, pos)
+	readBuf = make([]byte, 96)
+	_, err = io.ReadFull(brsc, readBuf)
+	assert.NoError(t, err)
+	assert.Equal(t, data[3904:], readBuf)
+	assert.LessOrEqual(t, outstanding(), int32(2))
+	assert.NoError(t, brsc.Close())
+	assert.EqualValues(t, 0, outstanding())
+
+	// the recycled mark is reported as released
+	brsc = bf.NewReader(&testReader{data: data})
+	token, err := brsc.(BufferMarker).Mark()
+	assert.NoError(t, err)
+	_, err = brsc.Seek(100, io.SeekStart)
+	assert.NoError(t, err)
+	assert.ErrorIs(t, brsc.(BufferMarker).Reset(token), ErrMarkReleased)
+	assert.NoError(t, brsc.Close())
+
+	// the views handed out by ReadSlice are not reused by the pool
+	brsc = bf.NewReader(&testReader{data: data})
+	view, err := brsc.(SliceReader).ReadSlice(4)
+	assert.NoError(t, err)
+	assert.Equal(t, data[:4], view)
+	_, err = brsc.Seek(2000, io.SeekStart)
+	assert.NoError(t, err)
+	other := bf.NewReader(&testReader{data: make([]byte, 100)})
+	_, err = io.Copy(Discard, other)
+	assert.NoError(t, err)
+	assert.Equal(t, data[:4], view)
+	assert.NoError(t, other.Close())
+	assert.NoError(t, brsc.Close())
+}
+
+func TestFirstByteLatency(t *testing.T) {
+	var latencies []time.Duration
+	bf := NewBufferReadSeekCloserFactory(OptionWithFirstByteLatency(func(d time.Duration) {
+		latencies = append(latencies, d)
+	}))
+	delay := 20 * time.Millisecond
+	brsc := bf.NewReader(&testDelayedReader{r: &testReader{data: []byte("1234567890")}, delay: delay})
+
+	readBuf := make([]byte, 5)
+	_, err := io.ReadFull(brsc, readBuf)
+	assert.NoError(t, err)
+	_, err = io.ReadFull(brsc, readBuf)
+	assert.NoError(t, err)
+
+	// reported once
+	assert.Len(t, latencies, 1)
+	assert.GreaterOrEqual(t, int64(latencies[0]), int64(delay))
+	assert.NoError(t, brsc.Close())
+}
+
+func TestClampSeek(t *testing.T) {
+	tp := &testPool{p: newPool(5)}
+	bf := NewBufferReadSeekCloserFactory(OptionWithPool(tp), OptionWithClampSeek())
+	brsc := bf.NewReader(&testReader{data: []byte("1234567890qwertyuiop")})
+	sc, ok := brsc.(SeekClamper)
+	assert.True(t, ok)
+
+	pos, clamped, err := sc.SeekClamped(8, io.SeekStart)
+	assert.NoError(t, err)
+	assert.False(t, clamped)
+	assert.EqualValues(t, 8, pos)
+
+	pos, clamped, err = sc.SeekClamped(30, io.SeekStart)
+	assert.NoError(t, err)
+	assert.True(t, clamped)
+	assert.EqualValues(t, 20, pos)
+
+	n, err := brsc.Read(make([]byte, 5))
+	assert.ErrorIs(t, err, io.EOF)
+	assert.EqualValues(t, 0, n)
+
+	pos, err = brsc.Seek(5, io.SeekEnd)
+	assert.NoError(t, err)
+	assert.EqualValues(t, 20, pos)
+
+	_, err = brsc.Seek(-1, io.SeekStart)
+	assert.ErrorIs(t, err, ErrSeekerOutOfRange)
+
+	assert.NoError(t, brsc.Close())
+	assert.EqualValues(t, 0, tp.Diff())
+
+	// without the option
+	brsc = NewBufferReadSeekCloserFactory().NewReader(&testReader{data: []byte("1234567890")})
+	pos, clamped, err = brsc.(SeekClamper).SeekClamped(30, io.SeekStart)
+	assert.ErrorIs(t, err, ErrSeekerOutOfRange)
+	assert.False(t, clamped)
+	assert.EqualValues(t, 0, pos)
+	assert.NoError(t, brsc.Close())
+}
+
+func TestCompact(t *testing.T) {
+	tp := &testPool{p: newPool(4)}
+	bf := NewBufferReadSeekCloserFactory(OptionWithPool(tp), OptionWithRingBuffer(2))
+	data := []byte("1234567890qwertyuiop")
+	brsc := bf.NewReader(&testDataEOFReader{data: data, n: 3})
+	c, ok := brsc.(Compacter)
+	assert.True(t, ok)
+
+	readBuf := make([]byte, 3)
+	_, err := io.ReadFull(brsc, readBuf)
+	assert.NoError(t, err)
+	_, err = brsc.Seek(13, io.SeekStart)
+	assert.NoError(t, err)
+	_, err = io.ReadFull(brsc, readBuf)
+	assert.NoError(t, err)
+	assert.Equal(t, data[13:16], readBuf)
+
+	// the buffers released by the ring are still in the index
+	br := brsc.(*bufReader)
+	assert.Len(t, br.buffer, 4)
+	assert.EqualValues(t, 8, brsc.(MemoryReporter).MemUsage())
+
+	assert.NoError(t, c.Compact())
+	assert.Len(t, br.buffer, 2)
+	assert.EqualValues(t, 8, brsc.(MemoryReporter).MemUsage())
+	assert.EqualValues(t, 2, tp.Diff())
+	pos, err := brsc.Seek(0, io.SeekCurrent)
+	assert.NoError(t, err)
+	assert.EqualValues(t, 16, pos)
+
+	// the seeks within the retained data and the following reads are not affected
+	_, err = brsc.Seek(7, io.SeekStart)
+	assert.ErrorIs(t, err, ErrSeekerOutOfRange)
+	for _, offset := range []int64{10, 8, 14} {
+		_, err = brsc.Seek(offset, io.SeekStart)
+		assert.NoError(t, err)
+		_, err = io.ReadFull(brsc, readBuf)
+		assert.NoError(t, err)
+		assert.Equal(t, data[offset:offset+3], readBuf)
+	}
+	buf := &bytes.Buffer{}
+	_, err = io.Copy(buf, brsc)
+	assert.NoError(t, err)
+	assert.Equal(t, data[17:], buf.Bytes())
+	_, err = brsc.Seek(-3, io.SeekEnd)
+	assert.NoError(t, err)
+	_, err = io.ReadFull(brsc, readBuf)
+	assert.NoError(t, err)
+	assert.Equal(t, data[17:], readBuf)
+
+	assert.NoError(t, c.Compact())
+	assert.NoError(t, brsc.Close())
+	assert.ErrorIs(t, c.Compact(), ErrClosed)
+	assert.EqualValues(t, 0, tp.Diff())
+}
+
+func TestSeekFraction(t *testing.T) {
+	tp := &testPool{p: newPool(5)}
+	bf := NewBufferReadSeekCloserFactory(OptionWithPool(tp))
+	brsc := bf.NewReader(&testReader{data: []byte("1234567890qwertyuiop")})
+	fs, ok := brsc.(FractionSeeker)
+	assert.True(t, ok)
+
+	_, err := fs.SeekFraction(0.5)
+	assert.ErrorIs(t, err, ErrSizeUnknown)
+
+	// fully buffered
+	_, err = brsc.Seek(0, io.SeekEnd)
+	assert.NoError(t, err)
+
+	pos, err := fs.SeekFraction(0.5)
+	assert.NoError(t, err)
+	assert.EqualValues(t, 10, pos)
+	readBuf := make([]byte, 5)
+	n, err := brsc.Read(readBuf)
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("qwert"), readBuf[:n])
+
+	pos, err = fs.SeekFraction(1)
+	assert.NoError(t, err)
+	assert.EqualValues(t, 20, pos)
+
+	for _, f := range []float64{-0.1, 1.1} {
+		_, err = fs.SeekFraction(f)
+		assert.ErrorIs(t, err, ErrSeekerOutOfRange)
+	}
+
+	assert.NoError(t, brsc.Close())
+	assert.EqualValues(t, 0, tp.Diff())
+
+	// the size reported by the source
+	brsc = bf.NewReader(&testSizedReader{testReader{data: []byte("1234567890")}})
+	pos, err = brsc.(FractionSeeker).SeekFraction(0.3)
+	assert.NoError(t, err)
+	assert.EqualValues(t, 3, pos)
+	assert.NoError(t, brsc.Close())
+	assert.EqualValues(t, 0, tp.Diff())
+}
+
+func TestIdleTimeout(t *testing.T) {
+	tp := &testPool{p: newPool(5)}
+	bf := NewBufferReadSeekCloserFactory(OptionWithPool(tp), OptionWithIdleTimeout(20*time.Millisecond))
+	brsc := bf.NewReader(&testReader{data: []byte("1234567890qwertyuiop")})
+
+	_, err := brsc.Seek(10, io.SeekStart)
+	assert.NoError(t, err)
+	assert.EqualValues(t, 2, tp.Diff())
+
+	// the activity keeps the buffers
+	readBuf := make([]byte, 1)
+	for i := 0; i < 5; i++ {
+		time.Sleep(5 * time.Millisecond)
+		_, err = brsc.Read(readBuf)
+		assert.NoError(t, err)
+	}
+	assert.EqualValues(t, 3, tp.Diff())
+
+	// so does any other method using the position
+	for i := 0; i < 5; i++ {
+		time.Sleep(5 * time.Millisecond)
+		_, err = brsc.(BufferedSeeker).SeekBuffered(12, io.SeekStart)
+		assert.NoError(t, err)
+		time.Sleep(5 * time.Millisecond)
+		_, err = brsc.(SliceReader).ReadSlice(3)
+		assert.NoError(t, err)
+	}
+	assert.EqualValues(t, 3, tp.Diff())
+
+	// the buffers behind the current position are released
+	assert.Eventually(t, func() bool {
+		return tp.Diff() == 0
+	}, 5*time.Second, 5*time.Millisecond)
+	_, err = brsc.Seek(0, io.SeekStart)
+	assert.ErrorIs(t, err, ErrSeekerDisabled)
+
+	// the stream goes on
+	n, err := brsc.Read(make([]byte, 5))
+	assert.NoError(t, err)
+	assert.EqualValues(t, 5, n)
+
+	assert.NoError(t, brsc.Close())
+	assert.EqualValues(t, 0, tp.Diff())
+}
+
+func TestFork(t *testing.T) {
+	tp := &testPool{p: newPool(4)}
+	bf := NewBufferReadSeekCloserFactory(OptionWithPool(tp))
+	brsc := bf.NewReader(&testReader{data: []byte("1234567890qwertyuiop")})
+
+	readBuf := make([]byte, 6)
+	_, err := io.ReadFull(brsc, readBuf)
+	assert.NoError(t, err)
+
+	fork, err := brsc.(Forker).Fork()
+	assert.NoError(t, err)
+
+	forkData := &bytes.Buffer{}
+	_, err = io.Copy(forkData, fork)
+	assert.NoError(t, err)
+	assert.Equal(t, "7890qwertyuiop", forkData.String())
+
+	// the original continues from its own position
+	n, err := io.ReadFull(brsc, readBuf)
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("7890qw"), readBuf[:n])
+
+	// the fork is independent and seekable
+	pos, err := fork.Seek(4, io.SeekStart)
+	assert.NoError(t, err)
+	assert.EqualValues(t, 4, pos)
+	assert.NoError(t, fork.Close())
+
+	n, err = io.ReadFull(brsc, readBuf)
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("ertyui"), readBuf[:n])
+
+	brsc.DisableSeeker()
+	_, err = brsc.(Forker).Fork()
+	assert.ErrorIs(t, err, ErrSeekerDisabled)
+
+	assert.NoError(t, brsc.Close())
+	assert.EqualValues(t, 0, tp.Diff())
+}
+
+func TestDisableSeekerDuringRead(t *testing.T) {
+	data := []byte("1234567890qwertyuiopasdfghjklzxcvbnm")
+	for i := 0; i < 50; i++ {
+		tp := &testPool{p: newPool(4)}
+		bf := NewBufferReadSeekCloserFactory(OptionWithPool(tp))
+		brsc := bf.NewReader(&testReader{data: data})
+
+		start := make(chan struct{})
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			<-start
+			brsc.DisableSeeker()
+		}()
+
+		// the read racing with DisableSeeker is either buffered or direct, no data is lost or duplicated
+		result := &bytes.Buffer{}
+		readBuf := make([]byte, 3)
+		close(start)
+		for {
+			n, err := brsc.Read(readBuf)
+			result.Write(readBuf[:n])
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			assert.NoError(t, err)
+		}
+		<-done
+
+		assert.Equal(t, string(data), result.String())
+		assert.NoError(t, brsc.Close())
+		assert.EqualValues(t, 0, tp.Diff())
+	}
+}
+
+func TestInternalState(t *testing.T) {
+	bf := NewBufferReadSeekCloserFactory(OptionWithPool(&shortPool{bufSize: 5, shortSize: 3}))
+	brsc := bf.NewReader(&testReader{data: []byte("1234567890qwertyuiop")})
+
+	_, err := brsc.Seek(7, io.SeekStart)
+	assert.NoError(t, err)
+
+	// the position is not backed by the buffers, the error is reported instead of panicking
+	_, err = io.ReadFull(brsc, make([]byte, 5))
+	assert.ErrorIs(t, err, ErrInternalState)
+
+	_, err = brsc.(SliceReader).ReadSlice(5)
+	assert.ErrorIs(t, err, ErrInternalState)
+
+	assert.NoError(t, brsc.Close())
+}
+
+func TestKeepUnderlyingSeek(t *testing.T) {
+	bf := NewBufferReadSeekCloserFactory(OptionKeepUnderlyingSeek())
+	brsc := bf.NewReader(bytes.NewReader([]byte("1234567890")))
+
+	readBuf := make([]byte, 3)
+	_, err := io.ReadFull(brsc, readBuf)
+	assert.NoError(t, err)
+
+	assert.NoError(t, brsc.DisableSeekerE())
+	brsc.DisableSeeker()
+
+	pos, err := brsc.Seek(1, io.SeekStart)
+	assert.NoError(t, err)
+	assert.EqualValues(t, 1, pos)
+	n, err := io.ReadFull(brsc, readBuf)
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("234"), readBuf[:n])
+	assert.NoError(t, brsc.Close())
+
+	// the buffered source is disabled as usual
+	brsc = bf.NewReader(&testReader{data: []byte("1234567890")})
+	brsc.DisableSeeker()
+	_, err = brsc.Seek(1, io.SeekStart)
+	assert.ErrorIs(t, err, ErrSeekerDisabled)
+	assert.NoError(t, brsc.Close())
+}
+
+func TestReadReverse(t *testing.T) {
+	tp := &testPool{p: newPool(4)}
+	bf := NewBufferReadSeekCloserFactory(OptionWithPool(tp))
+	data := []byte("1234567890qwertyuiop")
+	brsc := bf.NewReader(&testReader{data: data})
+	rr, ok := brsc.(ReverseReader)
+	assert.True(t, ok)
+
+	reversed := make([]byte, len(data))
+	for i := range data {
+		reversed[i] = data[len(data)-1-i]
+	}
+
+	_, err := brsc.Seek(0, io.SeekEnd)
+	assert.NoError(t, err)
+
+	result := &bytes.Buffer{}
+	readBuf := make([]byte, 3)
+	for {
+		n, err := rr.ReadReverse(readBuf)
+		result.Write(readBuf[:n])
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		assert.NoError(t, err)
+	}
+	assert.Equal(t, reversed, result.Bytes())
+
+	// from the middle, the stream is buffered by the first call
+	brsc2 := bf.NewReader(&testReader{data: data})
+	_, err = brsc2.Seek(6, io.SeekStart)
+	assert.NoError(t, err)
+	n, err := brsc2.(ReverseReader).ReadReverse(readBuf)
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("654"), readBuf[:n])
+	pos, err := brsc2.Seek(0, io.SeekCurrent)
+	assert.NoError(t, err)
+	assert.EqualValues(t, 3, pos)
+	_, buffered, err := brsc2.(SeekTracker).SeekTracked(0, io.SeekEnd)
+	assert.NoError(t, err)
+	assert.EqualValues(t, 0, buffered)
+
+	assert.NoError(t, brsc.Close())
+	assert.NoError(t, brsc2.Close())
+	assert.EqualValues(t, 0, tp.Diff())
+}
+
+func TestDrainToBufferedThenStream(t *testing.T) {
+	tests := []struct {
+		name  string
+		drain func(brsc BufferReadSeekCloser, w io.Writer) (int64, error)
+	}{
+		{
+			name: "DrainTo",
+			drain: func(brsc BufferReadSeekCloser, w io.Writer) (int64, error) {
+				return brsc.(BufferDrainer).DrainTo(w)
+			},
+		},
+		{
+			name: "CopyBufferedThenStream",
+			drain: func(brsc BufferReadSeekCloser, w io.Writer) (int64, error) {
+				return brsc.(BufferStreamer).CopyBufferedThenStream(w)
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			tp := &testPool{p: newPool(4)}
+			bf := NewBufferReadSeekCloserFactory(OptionWithPool(tp))
+			data := []byte("1234567890qwertyuiop")
+			brsc := bf.NewReader(&testReader{data: data})
+
+			// buffer up to 10, but consume only up to 3
+			_, err := brsc.Seek(10, io.SeekStart)
+			assert.NoError(t, err)
+			_, err = brsc.Seek(3, io.SeekStart)
+			assert.NoError(t, err)
+
+			w := &bytes.Buffer{}
+			n, err := test.drain(brsc, w)
+			assert.NoError(t, err)
+			assert.EqualValues(t, 17, n)
+			assert.Equal(t, data[3:], w.Bytes())
+			assert.EqualValues(t, 0, tp.Diff())
+
+			_, err = brsc.Seek(0, io.SeekStart)
+			assert.ErrorIs(t, err, ErrSeekerDisabled)
+
+			assert.NoError(t, brsc.Close())
+			assert.EqualValues(t, 0, tp.Diff())
+		})
+	}
+}
+
+func TestNewReaderWithSizeHint(t *testing.T) {
+	bf := NewBufferReadSeekCloserFactory()
+	data := []byte("1234567890qwertyuiop")
+
+	tests := []struct {
+		name        string
+		hint        int64
+		wantBufSize int
+	}{
+		{name: "unknown", hint: 0, wantBufSize: DefaultBufferSize},
+		{name: "small", hint: 20, wantBufSize: MinSizeHintBufferSize},
+		{name: "medium", hint: 1024 * 1024, wantBufSize: 64 * 1024},
+		{name: "large", hint: 1 << 40, wantBufSize: MaxSizeHintBufferSize},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			brsc := bf.NewReaderWithSizeHint(&testReader{data: data}, test.hint)
+			defer func() {
+				err := brsc.Close()
+				assert.NoError(t, err)
+			}()
+			assert.EqualValues(t, test.wantBufSize, brsc.(*bufReader).bufSize)
+
+			buf := &bytes.Buffer{}
+			_, err := io.Copy(buf, brsc)
+			assert.NoError(t, err)
+			assert.Equal(t, data, buf.Bytes())
+		})
+	}
+}
+
 // todo concurrent test
 
 func BenchmarkBufferWithPool(b *testing.B) {
@@ -518,6 +2710,169 @@ func BenchmarkBufferWithoutPool(b *testing.B) {
 	}
 }
 
+func BenchmarkWriteToWithWriterToSource(b *testing.B) {
+	data := make([]byte, 32*1024*1024)
+	bf := NewBufferReadSeekCloserFactory()
+
+	for i := 0; i < b.N; i++ {
+		benchmarkForwardScenario(bf, &testWriterToReader{r: bytes.NewReader(data)})
+	}
+}
+
+func BenchmarkWriteToWithReaderSource(b *testing.B) {
+	data := make([]byte, 32*1024*1024)
+	bf := NewBufferReadSeekCloserFactory()
+
+	for i := 0; i < b.N; i++ {
+		benchmarkForwardScenario(bf, &testReader{data: data})
+	}
+}
+
+func BenchmarkSmallInputInline(b *testing.B) {
+	data := make([]byte, 512)
+	bf := NewBufferReadSeekCloserFactory(OptionWithInlineThreshold(1024))
+
+	for i := 0; i < b.N; i++ {
+		benchmarkSmallScenario(bf, data)
+	}
+}
+
+func BenchmarkSmallInputPooled(b *testing.B) {
+	data := make([]byte, 512)
+	bf := NewBufferReadSeekCloserFactory()
+
+	for i := 0; i < b.N; i++ {
+		benchmarkSmallScenario(bf, data)
+	}
+}
+
+func benchmarkSmallScenario(bf BufferReadSeekCloserFactory, data []byte) {
+	r := bf.NewReader(&testSizedReader{testReader{data: data}})
+	defer r.Close()
+
+	_, err := io.Copy(Discard, r)
+	if err != nil {
+		panic(err)
+	}
+
+	_, err = r.Seek(0, io.SeekStart)
+	if err != nil {
+		panic(err)
+	}
+
+	_, err = io.Copy(Discard, r)
+	if err != nil {
+		panic(err)
+	}
+}
+
+func BenchmarkSeekEndSizedSource(b *testing.B) {
+	data := make([]byte, 32*1024*1024)
+	bf := NewBufferReadSeekCloserFactory()
+
+	for i := 0; i < b.N; i++ {
+		benchmarkSeekEndScenario(bf, &testSizedReader{testReader{data: data}})
+	}
+}
+
+func BenchmarkSeekEndUnsizedSource(b *testing.B) {
+	data := make([]byte, 32*1024*1024)
+	bf := NewBufferReadSeekCloserFactory()
+
+	for i := 0; i < b.N; i++ {
+		benchmarkSeekEndScenario(bf, &testReader{data: data})
+	}
+}
+
+func benchmarkSeekEndScenario(bf BufferReadSeekCloserFactory, source io.Reader) {
+	r := bf.NewReader(source)
+	defer r.Close()
+
+	// read the header, then the trailer
+	_, err := io.CopyN(Discard, r, 1024)
+	if err != nil {
+		panic(err)
+	}
+
+	_, err = r.Seek(-(32*1024*1024 - 2048), io.SeekEnd)
+	if err != nil {
+		panic(err)
+	}
+
+	_, err = io.CopyN(Discard, r, 1024)
+	if err != nil {
+		panic(err)
+	}
+}
+
+func BenchmarkCachedStreamReadSlice(b *testing.B) {
+	r := newCachedStream(make([]byte, 1024*1024))
+	defer r.Close()
+	slicer := r.(SliceReader)
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		_, err := r.Seek(0, io.SeekStart)
+		if err != nil {
+			panic(err)
+		}
+		for {
+			_, err = slicer.ReadSlice(32 * 1024)
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			if err != nil {
+				panic(err)
+			}
+		}
+	}
+}
+
+func BenchmarkCachedStreamRead(b *testing.B) {
+	r := newCachedStream(make([]byte, 1024*1024))
+	defer r.Close()
+	readBuf := make([]byte, 32*1024)
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		_, err := r.Seek(0, io.SeekStart)
+		if err != nil {
+			panic(err)
+		}
+		for {
+			_, err = r.Read(readBuf)
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			if err != nil {
+				panic(err)
+			}
+		}
+	}
+}
+
+func newCachedStream(data []byte) BufferReadSeekCloser {
+	r := NewBufferReadSeekCloserFactory().NewReader(&testReader{data: data})
+	_, err := r.Seek(0, io.SeekEnd)
+	if err != nil {
+		panic(err)
+	}
+	return r
+}
+
+func benchmarkForwardScenario(bf BufferReadSeekCloserFactory, source io.Reader) {
+	r := bf.NewReader(source)
+	defer r.Close()
+
+	r.DisableSeeker()
+
+	// hide the ReaderFrom of Discard, so the copies are not skipped by io.Copy
+	_, err := io.Copy(struct{ io.Writer }{Discard}, r)
+	if err != nil {
+		panic(err)
+	}
+}
+
 func benchmarkScenario(data []byte, bf BufferReadSeekCloserFactory, output io.Writer, readLength int64) {
 	r := bf.NewReader(&testReader{data: data})
 
@@ -0,0 +1,45 @@
+package io
+
+import (
+	"crypto/cipher"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"io"
+)
+
+// NewHexReader will decode the hex encoded r on the fly and buffer the decoded bytes for seeking.
+// Invalid input is reported by the read triggering the decoding.
+func NewHexReader(factory BufferReadSeekCloserFactory, r io.Reader) BufferReadSeekCloser {
+	if factory == nil {
+		factory = NewBufferReadSeekCloserFactory()
+	}
+	return factory.NewReader(hex.NewDecoder(r))
+}
+
+// NewBase64Reader will decode the base64 encoded r on the fly and buffer the decoded bytes for seeking.
+// Invalid input is reported by the read triggering the decoding. enc defaults to base64.StdEncoding.
+func NewBase64Reader(factory BufferReadSeekCloserFactory, enc *base64.Encoding, r io.Reader) BufferReadSeekCloser {
+	if factory == nil {
+		factory = NewBufferReadSeekCloserFactory()
+	}
+	if enc == nil {
+		enc = base64.StdEncoding
+	}
+	return factory.NewReader(base64.NewDecoder(enc, r))
+}
+
+// NewCTRDecryptReader will decrypt the CTR mode encrypted r on the fly and buffer the plaintext for seeking.
+// Seeking forward advances the keystream by decrypting further. It returns ErrInvalidIVLength if iv does not have
+// the length of block.BlockSize().
+func NewCTRDecryptReader(
+	factory BufferReadSeekCloserFactory, r io.Reader, block cipher.Block, iv []byte,
+) (BufferReadSeekCloser, error) {
+	if len(iv) != block.BlockSize() {
+		return nil, fmt.Errorf("%w: %d, expected %d", ErrInvalidIVLength, len(iv), block.BlockSize())
+	}
+	if factory == nil {
+		factory = NewBufferReadSeekCloserFactory()
+	}
+	return factory.NewReader(&cipher.StreamReader{S: cipher.NewCTR(block, iv), R: r}), nil
+}
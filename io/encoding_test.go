@@ -0,0 +1,120 @@
+package io
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"encoding/base64"
+	"encoding/hex"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEncodingReader(t *testing.T) {
+	data := []byte("1234567890qwertyuiop")
+
+	tests := []struct {
+		name   string
+		reader func(bf BufferReadSeekCloserFactory) BufferReadSeekCloser
+	}{
+		{
+			name: "hex",
+			reader: func(bf BufferReadSeekCloserFactory) BufferReadSeekCloser {
+				return NewHexReader(bf, strings.NewReader(hex.EncodeToString(data)))
+			},
+		},
+		{
+			name: "base64 std",
+			reader: func(bf BufferReadSeekCloserFactory) BufferReadSeekCloser {
+				return NewBase64Reader(bf, nil, strings.NewReader(base64.StdEncoding.EncodeToString(data)))
+			},
+		},
+		{
+			name: "base64 raw url",
+			reader: func(bf BufferReadSeekCloserFactory) BufferReadSeekCloser {
+				return NewBase64Reader(bf, base64.RawURLEncoding, strings.NewReader(base64.RawURLEncoding.EncodeToString(data)))
+			},
+		},
+		{
+			name: "ctr",
+			reader: func(bf BufferReadSeekCloserFactory) BufferReadSeekCloser {
+				block, err := aes.NewCipher([]byte("0123456789abcdef"))
+				assert.NoError(t, err)
+				iv := []byte("fedcba9876543210")
+
+				encrypted := make([]byte, len(data))
+				cipher.NewCTR(block, iv).XORKeyStream(encrypted, data)
+				brsc, err := NewCTRDecryptReader(bf, bytes.NewBuffer(encrypted), block, iv)
+				assert.NoError(t, err)
+				return brsc
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			tp := &testPool{p: newPool(5)}
+			brsc := test.reader(NewBufferReadSeekCloserFactory(OptionWithPool(tp)))
+			defer func() {
+				err := brsc.Close()
+				assert.NoError(t, err)
+				assert.EqualValues(t, 0, tp.Diff())
+			}()
+			readBuf := make([]byte, 5)
+
+			seek, err := brsc.Seek(10, io.SeekStart)
+			assert.NoError(t, err)
+			assert.EqualValues(t, 10, seek)
+
+			n, err := brsc.Read(readBuf)
+			assert.NoError(t, err)
+			assert.EqualValues(t, 5, n)
+			assert.Equal(t, []byte("qwert"), readBuf[:n])
+
+			seek, err = brsc.Seek(-12, io.SeekCurrent)
+			assert.NoError(t, err)
+			assert.EqualValues(t, 3, seek)
+
+			n, err = brsc.Read(readBuf)
+			assert.NoError(t, err)
+			assert.EqualValues(t, 5, n)
+			assert.Equal(t, []byte("45678"), readBuf[:n])
+
+			seek, err = brsc.Seek(0, io.SeekEnd)
+			assert.NoError(t, err)
+			assert.EqualValues(t, len(data), seek)
+		})
+	}
+}
+
+func TestEncodingReaderInvalidInput(t *testing.T) {
+	bf := NewBufferReadSeekCloserFactory()
+
+	brsc := NewHexReader(bf, strings.NewReader("31zz"))
+	buf := &bytes.Buffer{}
+	_, err := io.Copy(buf, brsc)
+	var invalidByteErr hex.InvalidByteError
+	assert.ErrorAs(t, err, &invalidByteErr)
+	assert.NoError(t, brsc.Close())
+
+	brsc = NewHexReader(nil, strings.NewReader("313"))
+	buf.Reset()
+	_, err = io.Copy(buf, brsc)
+	assert.ErrorIs(t, err, io.ErrUnexpectedEOF)
+	assert.Equal(t, "1", buf.String())
+	assert.NoError(t, brsc.Close())
+
+	brsc = NewBase64Reader(bf, nil, strings.NewReader("MTIz!!!!"))
+	_, err = brsc.Seek(0, io.SeekEnd)
+	var corruptInputErr base64.CorruptInputError
+	assert.ErrorAs(t, err, &corruptInputErr)
+	assert.NoError(t, brsc.Close())
+
+	block, err := aes.NewCipher([]byte("0123456789abcdef"))
+	assert.NoError(t, err)
+	brsc, err = NewCTRDecryptReader(bf, strings.NewReader("encrypted"), block, []byte("short iv"))
+	assert.ErrorIs(t, err, ErrInvalidIVLength)
+	assert.Nil(t, brsc)
+}
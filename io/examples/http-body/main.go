@@ -64,8 +64,7 @@ func server(ctx context.Context, listener net.Listener) {
 		body := brscFactory.NewReader(r.Body)
 
 		defer func() {
-			_, _ = io.Copy(sdkIo.Discard, body) // drain body
-			_ = body.Close()
+			_ = sdkIo.DrainAndClose(body)
 		}()
 
 		buf := &bytes.Buffer{}
@@ -94,7 +93,10 @@ func server(ctx context.Context, listener net.Listener) {
 		if err != nil {
 			panic(err)
 		}
-		body.DisableSeeker()
+		err = body.DisableSeekerE()
+		if err != nil {
+			panic(err)
+		}
 
 		buf.Reset()
 
@@ -0,0 +1,265 @@
+package io
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// NewHTTPRangeReader will GET the url and return a BufferReadSeekCloser over the response body.
+// If the server advertises "Accept-Ranges: bytes", Seek is done by issuing a Range request from the new position.
+// The Range requests carry If-Range with the ETag or the Last-Modified of the first response, and
+// ErrResourceChanged is returned once the resource is modified. Otherwise the body is buffered by the factory.
+// The factory is taken instead of a buffer size, so the buffers are reused across the readers like the other
+// constructors of this package. A nil factory uses NewBufferReadSeekCloserFactory, a nil client uses http.DefaultClient
+func NewHTTPRangeReader(factory BufferReadSeekCloserFactory, client *http.Client, url string) (BufferReadSeekCloser, error) {
+	if factory == nil {
+		factory = NewBufferReadSeekCloserFactory()
+	}
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		_ = resp.Body.Close()
+		cancel()
+		return nil, fmt.Errorf("%w: %s", ErrUnexpectedStatus, resp.Status)
+	}
+
+	if resp.Header.Get("Accept-Ranges") != "bytes" || resp.ContentLength < 0 {
+		body := &cancelOnClose{ReadCloser: resp.Body, cancel: cancel}
+		return factory.NewReader(body), nil
+	}
+
+	return &httpRangeReader{
+		ctx:       ctx,
+		cancelCtx: cancel,
+		client:    client,
+		url:       url,
+		size:      resp.ContentLength,
+		validator: rangeValidator(resp.Header),
+		body:      resp.Body,
+	}, nil
+}
+
+// rangeValidator returns the validator sent as If-Range. A weak ETag can not be used for a Range request,
+// so the Last-Modified is used instead
+func rangeValidator(header http.Header) string {
+	if etag := header.Get("ETag"); etag != "" && !strings.HasPrefix(etag, "W/") {
+		return etag
+	}
+	return header.Get("Last-Modified")
+}
+
+// rangeTotal returns the complete length of the Content-Range "bytes first-last/total", false if it is unknown
+func rangeTotal(contentRange string) (int64, bool) {
+	i := strings.LastIndexByte(contentRange, '/')
+	if i < 0 {
+		return 0, false
+	}
+	total, err := strconv.ParseInt(contentRange[i+1:], 10, 64)
+	if err != nil {
+		return 0, false
+	}
+	return total, true
+}
+
+// cancelOnClose will cancel the ctx of the request once the body is closed
+type cancelOnClose struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (c *cancelOnClose) Close() error {
+	defer c.cancel()
+	return c.ReadCloser.Close()
+}
+
+type httpRangeReader struct {
+	mu               sync.Mutex
+	isSeekerDisabled int32
+	isClosed         int32
+	currentPos       int64
+
+	ctx       context.Context
+	cancelCtx context.CancelFunc
+	client    *http.Client
+	url       string
+	size      int64
+	validator string
+	body      io.ReadCloser
+	bodyPos   int64
+}
+
+func (h *httpRangeReader) Read(p []byte) (n int, err error) {
+	if atomic.LoadInt32(&h.isClosed) == 1 {
+		return 0, ErrClosed
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.currentPos >= h.size {
+		return 0, io.EOF
+	}
+	if h.body == nil || h.bodyPos != h.currentPos {
+		err = h.requestRange(h.currentPos)
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	n, err = h.body.Read(p)
+	h.currentPos += int64(n)
+	h.bodyPos += int64(n)
+	switch {
+	case err != nil && atomic.LoadInt32(&h.isClosed) == 1:
+		// the read is interrupted by Close
+		err = ErrClosed
+	case n > 0 && errors.Is(err, io.EOF):
+		err = nil
+	}
+	return
+}
+
+// requestRange will replace the body by the response of a Range request starting from pos
+func (h *httpRangeReader) requestRange(pos int64) error {
+	if h.body != nil {
+		_ = h.body.Close()
+		h.body = nil
+	}
+
+	req, err := http.NewRequestWithContext(h.ctx, http.MethodGet, h.url, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Range", fmt.Sprintf("bytes=%d-", pos))
+	if h.validator != "" {
+		// the server responds the whole resource instead of the range once it is modified
+		req.Header.Set("If-Range", h.validator)
+	}
+
+	resp, err := h.client.Do(req)
+	if err != nil {
+		if atomic.LoadInt32(&h.isClosed) == 1 {
+			return ErrClosed
+		}
+		return err
+	}
+	switch {
+	case resp.StatusCode == http.StatusOK && h.validator != "":
+		_ = resp.Body.Close()
+		return ErrResourceChanged
+	case resp.StatusCode != http.StatusPartialContent:
+		_ = resp.Body.Close()
+		return fmt.Errorf("%w: %s", ErrUnexpectedStatus, resp.Status)
+	}
+	if total, ok := rangeTotal(resp.Header.Get("Content-Range")); ok && total != h.size {
+		// the resource without validator is modified
+		_ = resp.Body.Close()
+		return ErrResourceChanged
+	}
+
+	h.body = resp.Body
+	h.bodyPos = pos
+	return nil
+}
+
+func (h *httpRangeReader) Remaining() (int64, bool) {
+	if atomic.LoadInt32(&h.isClosed) == 1 {
+		return 0, false
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.size - h.currentPos, true
+}
+
+// Seek will only move the position, the Range request is issued by the next Read
+func (h *httpRangeReader) Seek(offset int64, whence int) (int64, error) {
+	if atomic.LoadInt32(&h.isClosed) == 1 {
+		return h.currentPos, ErrClosed
+	}
+	if atomic.LoadInt32(&h.isSeekerDisabled) == 1 {
+		return h.currentPos, ErrSeekerDisabled
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	var abs int64
+
+	switch whence {
+	case io.SeekStart:
+		abs = offset
+	case io.SeekCurrent:
+		abs = h.currentPos + offset
+	case io.SeekEnd:
+		abs = h.size + offset
+	default:
+		return h.currentPos, ErrSeekerInvalidWhence
+	}
+
+	if abs < 0 || abs > h.size {
+		return h.currentPos, ErrSeekerOutOfRange
+	}
+
+	h.currentPos = abs
+	return abs, nil
+}
+
+// Close will close the current response body
+func (h *httpRangeReader) Close() error {
+	if !atomic.CompareAndSwapInt32(&h.isClosed, 0, 1) {
+		return ErrClosed
+	}
+
+	// abort the in-flight request, so the lock is released
+	h.cancelCtx()
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.body == nil {
+		return nil
+	}
+	err := h.body.Close()
+	h.body = nil
+	return err
+}
+
+func (h *httpRangeReader) DisableSeeker() {
+	_ = h.DisableSeekerE()
+}
+
+func (h *httpRangeReader) SourceKind() string {
+	return SourceKindHTTPRange
+}
+
+func (h *httpRangeReader) DisableSeekerE() error {
+	if atomic.LoadInt32(&h.isClosed) == 1 {
+		return ErrClosed
+	}
+	if !atomic.CompareAndSwapInt32(&h.isSeekerDisabled, 0, 1) {
+		return ErrSeekerDisabled
+	}
+	return nil
+}
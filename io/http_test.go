@@ -0,0 +1,202 @@
+package io
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type testRecordTransport struct {
+	mu       sync.Mutex
+	ranges   []string
+	ifRanges []string
+	open     int32
+}
+
+func (t *testRecordTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	t.mu.Lock()
+	t.ranges = append(t.ranges, req.Header.Get("Range"))
+	t.ifRanges = append(t.ifRanges, req.Header.Get("If-Range"))
+	t.mu.Unlock()
+
+	resp, err := http.DefaultTransport.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	atomic.AddInt32(&t.open, 1)
+	resp.Body = &testRecordBody{ReadCloser: resp.Body, open: &t.open}
+	return resp, nil
+}
+
+type testRecordBody struct {
+	io.ReadCloser
+	once sync.Once
+	open *int32
+}
+
+func (t *testRecordBody) Close() error {
+	t.once.Do(func() {
+		atomic.AddInt32(t.open, -1)
+	})
+	return t.ReadCloser.Close()
+}
+
+func TestHTTPRangeReader(t *testing.T) {
+	content := "1234567890qwertyuiop"
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.ServeContent(w, r, "", time.Time{}, strings.NewReader(content))
+	}))
+	defer srv.Close()
+
+	transport := &testRecordTransport{}
+	brsc, err := NewHTTPRangeReader(nil, &http.Client{Transport: transport}, srv.URL)
+	assert.NoError(t, err)
+	readBuf := make([]byte, 5)
+
+	n, err := brsc.Read(readBuf[:3])
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("123"), readBuf[:n])
+
+	seek, err := brsc.Seek(-5, io.SeekEnd)
+	assert.NoError(t, err)
+	assert.EqualValues(t, 15, seek)
+
+	n, err = io.ReadFull(brsc, readBuf)
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("yuiop"), readBuf[:n])
+
+	seek, err = brsc.Seek(3, io.SeekStart)
+	assert.NoError(t, err)
+	assert.EqualValues(t, 3, seek)
+
+	buf := &bytes.Buffer{}
+	_, err = io.Copy(buf, brsc)
+	assert.NoError(t, err)
+	assert.Equal(t, content[3:], buf.String())
+
+	_, err = brsc.Seek(21, io.SeekStart)
+	assert.ErrorIs(t, err, ErrSeekerOutOfRange)
+
+	err = brsc.Close()
+	assert.NoError(t, err)
+	assert.EqualValues(t, 0, atomic.LoadInt32(&transport.open))
+	assert.Equal(t, []string{"", "bytes=15-", "bytes=3-"}, transport.ranges)
+
+	_, err = brsc.Read(readBuf)
+	assert.ErrorIs(t, err, ErrClosed)
+}
+
+func TestHTTPRangeReaderFallback(t *testing.T) {
+	content := "1234567890qwertyuiop"
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.WriteString(w, content)
+	}))
+	defer srv.Close()
+
+	tp := &testPool{p: newPool(5)}
+	bf := NewBufferReadSeekCloserFactory(OptionWithPool(tp))
+
+	transport := &testRecordTransport{}
+	brsc, err := NewHTTPRangeReader(bf, &http.Client{Transport: transport}, srv.URL)
+	assert.NoError(t, err)
+
+	seek, err := brsc.Seek(-5, io.SeekEnd)
+	assert.NoError(t, err)
+	assert.EqualValues(t, 15, seek)
+
+	seek, err = brsc.Seek(10, io.SeekStart)
+	assert.NoError(t, err)
+	assert.EqualValues(t, 10, seek)
+
+	buf := &bytes.Buffer{}
+	_, err = io.Copy(buf, brsc)
+	assert.NoError(t, err)
+	assert.Equal(t, content[10:], buf.String())
+	// the body is buffered by the given factory
+	assert.Greater(t, tp.Diff(), int32(0))
+
+	err = brsc.Close()
+	assert.NoError(t, err)
+	assert.EqualValues(t, 0, atomic.LoadInt32(&transport.open))
+	assert.Equal(t, []string{""}, transport.ranges)
+	assert.EqualValues(t, 0, tp.Diff())
+
+	// unexpected status
+	notFound := httptest.NewServer(http.NotFoundHandler())
+	defer notFound.Close()
+	_, err = NewHTTPRangeReader(bf, nil, notFound.URL)
+	assert.ErrorIs(t, err, ErrUnexpectedStatus)
+}
+
+func TestHTTPRangeReaderResourceChanged(t *testing.T) {
+	tests := []struct {
+		name    string
+		etag    func(version string) string
+		ifRange string
+	}{
+		{
+			name:    "etag",
+			etag:    func(version string) string { return `"` + version + `"` },
+			ifRange: `"v1"`,
+		},
+		{
+			// the size is checked against the Content-Range instead
+			name: "no validator",
+			etag: func(version string) string { return "" },
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var (
+				mu      sync.Mutex
+				version = "v1"
+				content = "1234567890qwertyuiop"
+			)
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				mu.Lock()
+				etag, data := test.etag(version), content
+				mu.Unlock()
+				if etag != "" {
+					w.Header().Set("ETag", etag)
+				}
+				http.ServeContent(w, r, "", time.Time{}, strings.NewReader(data))
+			}))
+			defer srv.Close()
+
+			transport := &testRecordTransport{}
+			brsc, err := NewHTTPRangeReader(nil, &http.Client{Transport: transport}, srv.URL)
+			assert.NoError(t, err)
+			defer brsc.Close()
+			readBuf := make([]byte, 5)
+
+			// the unchanged resource is read by range
+			_, err = brsc.Seek(10, io.SeekStart)
+			assert.NoError(t, err)
+			n, err := io.ReadFull(brsc, readBuf)
+			assert.NoError(t, err)
+			assert.Equal(t, []byte("qwert"), readBuf[:n])
+
+			mu.Lock()
+			version, content = "v2", "1234567890QWERTYUIOP!"
+			mu.Unlock()
+
+			_, err = brsc.Seek(3, io.SeekStart)
+			assert.NoError(t, err)
+			_, err = brsc.Read(readBuf)
+			assert.ErrorIs(t, err, ErrResourceChanged)
+
+			transport.mu.Lock()
+			defer transport.mu.Unlock()
+			assert.Equal(t, []string{"", "bytes=10-", "bytes=3-"}, transport.ranges)
+			assert.Equal(t, []string{"", test.ifRange, test.ifRange}, transport.ifRanges)
+		})
+	}
+}
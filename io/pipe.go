@@ -0,0 +1,14 @@
+package io
+
+import "io"
+
+// NewPipeReader will create a seekable reader fed by the returned writer. The data written to the writer becomes
+// readable from the reader, a read or seek beyond the written data blocks until more data is written.
+// The reader reaches EOF once the writer is closed, closing the reader fails the following writes.
+func NewPipeReader(factory BufferReadSeekCloserFactory) (BufferReadSeekCloser, io.WriteCloser) {
+	if factory == nil {
+		factory = NewBufferReadSeekCloserFactory()
+	}
+	pr, pw := io.Pipe()
+	return factory.NewReader(pr), pw
+}
@@ -0,0 +1,55 @@
+package io
+
+import (
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPipeReader(t *testing.T) {
+	tp := &testPool{p: newPool(4)}
+	brsc, w := NewPipeReader(NewBufferReadSeekCloserFactory(OptionWithPool(tp)))
+
+	go func() {
+		for _, chunk := range []string{"12345", "67890", "qwert"} {
+			_, err := w.Write([]byte(chunk))
+			assert.NoError(t, err)
+		}
+		assert.NoError(t, w.Close())
+	}()
+
+	// wait for the data written by the second chunk
+	pos, err := brsc.Seek(7, io.SeekStart)
+	assert.NoError(t, err)
+	assert.EqualValues(t, 7, pos)
+
+	readBuf := make([]byte, 3)
+	n, err := io.ReadFull(brsc, readBuf)
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("890"), readBuf[:n])
+
+	pos, err = brsc.Seek(0, io.SeekStart)
+	assert.NoError(t, err)
+	assert.EqualValues(t, 0, pos)
+	n, err = io.ReadFull(brsc, readBuf)
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("123"), readBuf[:n])
+
+	// the end is known once the writer is closed
+	pos, err = brsc.Seek(0, io.SeekEnd)
+	assert.NoError(t, err)
+	assert.EqualValues(t, 15, pos)
+	n, err = brsc.Read(readBuf)
+	assert.ErrorIs(t, err, io.EOF)
+	assert.EqualValues(t, 0, n)
+
+	assert.NoError(t, brsc.Close())
+	assert.EqualValues(t, 0, tp.Diff())
+
+	// the write fails once the reader is closed
+	brsc, w = NewPipeReader(nil)
+	assert.NoError(t, brsc.Close())
+	_, err = w.Write([]byte("1"))
+	assert.ErrorIs(t, err, io.ErrClosedPipe)
+}
@@ -0,0 +1,147 @@
+package io
+
+import (
+	"errors"
+	"io"
+	"sync"
+	"sync/atomic"
+)
+
+// NewReaderFromReaderAt will create a BufferReadSeekCloser reading ra directly without buffering.
+// size is the total length of ra. Close will close ra if it implements io.Closer
+func NewReaderFromReaderAt(ra io.ReaderAt, size int64) BufferReadSeekCloser {
+	if size < 0 {
+		size = 0
+	}
+	return &readerAtReader{
+		readerAt: ra,
+		size:     size,
+	}
+}
+
+type readerAtReader struct {
+	mu               sync.Mutex
+	isSeekerDisabled int32
+	isClosed         int32
+	currentPos       int64
+
+	readerAt io.ReaderAt
+	size     int64
+}
+
+func (r *readerAtReader) Read(p []byte) (n int, err error) {
+	if atomic.LoadInt32(&r.isClosed) == 1 {
+		return 0, ErrClosed
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.currentPos >= r.size {
+		return 0, io.EOF
+	}
+	if remaining := r.size - r.currentPos; int64(len(p)) > remaining {
+		p = p[:remaining]
+	}
+
+	n, err = r.readerAt.ReadAt(p, r.currentPos)
+	r.currentPos += int64(n)
+	if n > 0 && errors.Is(err, io.EOF) {
+		err = nil
+	}
+	return
+}
+
+func (r *readerAtReader) ReadAt(p []byte, off int64) (n int, err error) {
+	if atomic.LoadInt32(&r.isClosed) == 1 {
+		return 0, ErrClosed
+	}
+	if off < 0 {
+		return 0, ErrSeekerOutOfRange
+	}
+	if off >= r.size {
+		return 0, io.EOF
+	}
+
+	if remaining := r.size - off; int64(len(p)) > remaining {
+		n, err = r.readerAt.ReadAt(p[:remaining], off)
+		if err == nil {
+			err = io.EOF
+		}
+		return
+	}
+	return r.readerAt.ReadAt(p, off)
+}
+
+func (r *readerAtReader) Remaining() (int64, bool) {
+	if atomic.LoadInt32(&r.isClosed) == 1 {
+		return 0, false
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.size - r.currentPos, true
+}
+
+func (r *readerAtReader) Seek(offset int64, whence int) (int64, error) {
+	if atomic.LoadInt32(&r.isClosed) == 1 {
+		return r.currentPos, ErrClosed
+	}
+	if atomic.LoadInt32(&r.isSeekerDisabled) == 1 {
+		return r.currentPos, ErrSeekerDisabled
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var abs int64
+
+	switch whence {
+	case io.SeekStart:
+		abs = offset
+	case io.SeekCurrent:
+		abs = r.currentPos + offset
+	case io.SeekEnd:
+		abs = r.size + offset
+	default:
+		return r.currentPos, ErrSeekerInvalidWhence
+	}
+
+	if abs < 0 || abs > r.size {
+		return r.currentPos, ErrSeekerOutOfRange
+	}
+
+	r.currentPos = abs
+	return abs, nil
+}
+
+func (r *readerAtReader) Close() error {
+	if !atomic.CompareAndSwapInt32(&r.isClosed, 0, 1) {
+		return ErrClosed
+	}
+
+	switch ra := r.readerAt.(type) {
+	case io.Closer:
+		return ra.Close()
+	default:
+		return nil
+	}
+}
+
+func (r *readerAtReader) DisableSeeker() {
+	_ = r.DisableSeekerE()
+}
+
+func (r *readerAtReader) SourceKind() string {
+	return SourceKindReaderAt
+}
+
+func (r *readerAtReader) DisableSeekerE() error {
+	if atomic.LoadInt32(&r.isClosed) == 1 {
+		return ErrClosed
+	}
+	if !atomic.CompareAndSwapInt32(&r.isSeekerDisabled, 0, 1) {
+		return ErrSeekerDisabled
+	}
+	return nil
+}
@@ -0,0 +1,144 @@
+package io
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type testReaderAtCloser struct {
+	*strings.Reader
+	closed int
+}
+
+func (t *testReaderAtCloser) Close() error {
+	t.closed++
+	return nil
+}
+
+func TestReaderFromReaderAt(t *testing.T) {
+	source := &testReaderAtCloser{Reader: strings.NewReader("1234567890qwertyuiop")}
+	brsc := NewReaderFromReaderAt(source, source.Size())
+	readBuf := make([]byte, 10)
+
+	n, err := brsc.Read(readBuf[:3])
+	assert.NoError(t, err)
+	assert.EqualValues(t, 3, n)
+	assert.Equal(t, []byte("123"), readBuf[:n])
+
+	// mid seek
+	seek, err := brsc.Seek(10, io.SeekStart)
+	assert.NoError(t, err)
+	assert.EqualValues(t, 10, seek)
+
+	n, err = brsc.Read(readBuf[:3])
+	assert.NoError(t, err)
+	assert.EqualValues(t, 3, n)
+	assert.Equal(t, []byte("qwe"), readBuf[:n])
+
+	seek, err = brsc.Seek(-8, io.SeekCurrent)
+	assert.NoError(t, err)
+	assert.EqualValues(t, 5, seek)
+
+	n, err = brsc.Read(readBuf[:3])
+	assert.NoError(t, err)
+	assert.EqualValues(t, 3, n)
+	assert.Equal(t, []byte("678"), readBuf[:n])
+
+	// out of range
+	seek, err = brsc.Seek(21, io.SeekStart)
+	assert.ErrorIs(t, err, ErrSeekerOutOfRange)
+	assert.EqualValues(t, 8, seek)
+
+	seek, err = brsc.Seek(-21, io.SeekEnd)
+	assert.ErrorIs(t, err, ErrSeekerOutOfRange)
+	assert.EqualValues(t, 8, seek)
+
+	seek, err = brsc.Seek(1, io.SeekEnd)
+	assert.ErrorIs(t, err, ErrSeekerOutOfRange)
+	assert.EqualValues(t, 8, seek)
+
+	seek, err = brsc.Seek(0, 4)
+	assert.ErrorIs(t, err, ErrSeekerInvalidWhence)
+	assert.EqualValues(t, 8, seek)
+
+	// seek to end
+	seek, err = brsc.Seek(-3, io.SeekEnd)
+	assert.NoError(t, err)
+	assert.EqualValues(t, 17, seek)
+
+	n, err = brsc.Read(readBuf)
+	assert.NoError(t, err)
+	assert.EqualValues(t, 3, n)
+	assert.Equal(t, []byte("iop"), readBuf[:n])
+
+	n, err = brsc.Read(readBuf)
+	assert.ErrorIs(t, err, io.EOF)
+	assert.EqualValues(t, 0, n)
+
+	seek, err = brsc.Seek(0, io.SeekEnd)
+	assert.NoError(t, err)
+	assert.EqualValues(t, 20, seek)
+
+	// forward only
+	seek, err = brsc.Seek(5, io.SeekStart)
+	assert.NoError(t, err)
+	assert.EqualValues(t, 5, seek)
+
+	err = brsc.DisableSeekerE()
+	assert.NoError(t, err)
+
+	seek, err = brsc.Seek(0, io.SeekStart)
+	assert.ErrorIs(t, err, ErrSeekerDisabled)
+	assert.EqualValues(t, 5, seek)
+
+	buf := &bytes.Buffer{}
+	written, err := io.Copy(buf, brsc)
+	assert.NoError(t, err)
+	assert.EqualValues(t, 15, written)
+	assert.Equal(t, "67890qwertyuiop", buf.String())
+
+	err = brsc.Close()
+	assert.NoError(t, err)
+	assert.EqualValues(t, 1, source.closed)
+
+	err = brsc.Close()
+	assert.ErrorIs(t, err, ErrClosed)
+	assert.EqualValues(t, 1, source.closed)
+
+	n, err = brsc.Read(readBuf)
+	assert.ErrorIs(t, err, ErrClosed)
+	assert.EqualValues(t, 0, n)
+}
+
+func TestReaderFromReaderAtReadAt(t *testing.T) {
+	// size is smaller than the source
+	brsc := NewReaderFromReaderAt(strings.NewReader("1234567890qwertyuiop"), 10)
+	defer brsc.Close()
+
+	readerAt, ok := brsc.(io.ReaderAt)
+	assert.True(t, ok)
+
+	readBuf := make([]byte, 5)
+	n, err := readerAt.ReadAt(readBuf, 7)
+	assert.ErrorIs(t, err, io.EOF)
+	assert.EqualValues(t, 3, n)
+	assert.Equal(t, []byte("890"), readBuf[:n])
+
+	n, err = readerAt.ReadAt(readBuf, 10)
+	assert.ErrorIs(t, err, io.EOF)
+	assert.EqualValues(t, 0, n)
+
+	n, err = readerAt.ReadAt(readBuf, -1)
+	assert.ErrorIs(t, err, ErrSeekerOutOfRange)
+	assert.EqualValues(t, 0, n)
+
+	buf := &bytes.Buffer{}
+	written, err := io.Copy(buf, brsc)
+	assert.NoError(t, err)
+	assert.EqualValues(t, 10, written)
+	assert.Equal(t, "1234567890", buf.String())
+}
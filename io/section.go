@@ -0,0 +1,135 @@
+package io
+
+import (
+	"errors"
+	"io"
+	"sync"
+	"sync/atomic"
+)
+
+// NewSectionReader will create a BufferReadSeekCloser reading r in the range of [off, off+n).
+// The section has its own position starting at zero, r must keep its seeker enabled.
+// Close will not close r.
+func NewSectionReader(r BufferReadSeekCloser, off, n int64) BufferReadSeekCloser {
+	if off < 0 {
+		off = 0
+	}
+	if n < 0 {
+		n = 0
+	}
+	return &sectionReader{
+		parent: r,
+		base:   off,
+		size:   n,
+	}
+}
+
+type sectionReader struct {
+	mu               sync.Mutex
+	isSeekerDisabled int32
+	isClosed         int32
+	currentPos       int64
+
+	parent BufferReadSeekCloser
+	base   int64
+	size   int64
+}
+
+func (s *sectionReader) Read(p []byte) (n int, err error) {
+	if atomic.LoadInt32(&s.isClosed) == 1 {
+		return 0, ErrClosed
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.currentPos >= s.size {
+		return 0, io.EOF
+	}
+	if remaining := s.size - s.currentPos; int64(len(p)) > remaining {
+		p = p[:remaining]
+	}
+
+	_, err = s.parent.Seek(s.base+s.currentPos, io.SeekStart)
+	if err != nil {
+		if errors.Is(err, ErrSeekerOutOfRange) {
+			err = io.EOF
+		}
+		return 0, err
+	}
+
+	n, err = s.parent.Read(p)
+	s.currentPos += int64(n)
+	return
+}
+
+func (s *sectionReader) Remaining() (int64, bool) {
+	if atomic.LoadInt32(&s.isClosed) == 1 {
+		return 0, false
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.currentPos >= s.size {
+		return 0, true
+	}
+	return s.size - s.currentPos, true
+}
+
+func (s *sectionReader) Seek(offset int64, whence int) (int64, error) {
+	if atomic.LoadInt32(&s.isClosed) == 1 {
+		return s.currentPos, ErrClosed
+	}
+	if atomic.LoadInt32(&s.isSeekerDisabled) == 1 {
+		return s.currentPos, ErrSeekerDisabled
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var abs int64
+
+	switch whence {
+	case io.SeekStart:
+		abs = offset
+	case io.SeekCurrent:
+		abs = s.currentPos + offset
+	case io.SeekEnd:
+		abs = s.size + offset
+	default:
+		return s.currentPos, ErrSeekerInvalidWhence
+	}
+
+	// like io.SectionReader, seeking past the end is allowed and the following reads return EOF
+	if abs < 0 {
+		return s.currentPos, ErrSeekerOutOfRange
+	}
+
+	s.currentPos = abs
+	return abs, nil
+}
+
+func (s *sectionReader) Close() error {
+	if !atomic.CompareAndSwapInt32(&s.isClosed, 0, 1) {
+		return ErrClosed
+	}
+	return nil
+}
+
+func (s *sectionReader) DisableSeeker() {
+	_ = s.DisableSeekerE()
+}
+
+func (s *sectionReader) SourceKind() string {
+	return SourceKindSection
+}
+
+func (s *sectionReader) DisableSeekerE() error {
+	if atomic.LoadInt32(&s.isClosed) == 1 {
+		return ErrClosed
+	}
+	if !atomic.CompareAndSwapInt32(&s.isSeekerDisabled, 0, 1) {
+		return ErrSeekerDisabled
+	}
+	return nil
+}
@@ -0,0 +1,94 @@
+package io
+
+import (
+	"bytes"
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSectionReader(t *testing.T) {
+	tp := &testPool{p: newPool(5)}
+	bf := NewBufferReadSeekCloserFactory(OptionWithPool(tp))
+
+	parent := bf.NewReader(&testReader{data: []byte("1234567890qwertyuiop")})
+	defer func() {
+		err := parent.Close()
+		assert.NoError(t, err)
+		assert.EqualValues(t, 0, tp.Diff())
+	}()
+
+	section := NewSectionReader(parent, 5, 10)
+	defer func() {
+		err := section.Close()
+		assert.NoError(t, err)
+	}()
+	readBuf := make([]byte, 20)
+
+	n, err := section.Read(readBuf[:3])
+	assert.NoError(t, err)
+	assert.EqualValues(t, 3, n)
+	assert.Equal(t, []byte("678"), readBuf[:n])
+
+	seek, err := section.Seek(-4, io.SeekEnd)
+	assert.NoError(t, err)
+	assert.EqualValues(t, 6, seek)
+
+	// reading past the end of section
+	n, err = section.Read(readBuf)
+	assert.NoError(t, err)
+	assert.EqualValues(t, 4, n)
+	assert.Equal(t, []byte("wert"), readBuf[:n])
+
+	n, err = section.Read(readBuf)
+	assert.ErrorIs(t, err, io.EOF)
+	assert.EqualValues(t, 0, n)
+
+	// seeking past the end is allowed, the reads there return EOF
+	seek, err = section.Seek(1, io.SeekEnd)
+	assert.NoError(t, err)
+	assert.EqualValues(t, 11, seek)
+
+	n, err = section.Read(readBuf)
+	assert.ErrorIs(t, err, io.EOF)
+	assert.EqualValues(t, 0, n)
+
+	remaining, ok := section.(BufferRemainer).Remaining()
+	assert.True(t, ok)
+	assert.EqualValues(t, 0, remaining)
+
+	seek, err = section.Seek(-12, io.SeekCurrent)
+	assert.ErrorIs(t, err, ErrSeekerOutOfRange)
+	assert.EqualValues(t, 11, seek)
+
+	seek, err = section.Seek(2, io.SeekStart)
+	assert.NoError(t, err)
+	assert.EqualValues(t, 2, seek)
+
+	buf := &bytes.Buffer{}
+	written, err := io.Copy(buf, section)
+	assert.NoError(t, err)
+	assert.EqualValues(t, 8, written)
+	assert.Equal(t, "890qwert", buf.String())
+
+	// the section beyond the parent
+	section = NewSectionReader(parent, 15, 10)
+	buf.Reset()
+	written, err = io.Copy(buf, section)
+	assert.NoError(t, err)
+	assert.EqualValues(t, 5, written)
+	assert.Equal(t, "yuiop", buf.String())
+
+	section = NewSectionReader(parent, 25, 10)
+	n, err = section.Read(readBuf)
+	assert.ErrorIs(t, err, io.EOF)
+	assert.EqualValues(t, 0, n)
+
+	// the parent must keep its seeker enabled
+	parent.DisableSeeker()
+	section = NewSectionReader(parent, 0, 10)
+	n, err = section.Read(readBuf)
+	assert.ErrorIs(t, err, ErrSeekerDisabled)
+	assert.EqualValues(t, 0, n)
+}
@@ -0,0 +1,116 @@
+package io
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+type slabPool struct {
+	bufSize  int
+	blocking bool
+	timeout  time.Duration
+	slab     []byte
+	free     chan *Buffer
+}
+
+// NewSlabPool will create a Pool handing out buffers from a single pre-allocated backing array.
+// Get returns ErrNoBuffersAvailable when all buffers are in use.
+func NewSlabPool(bufferSize, slabCount int) Pool {
+	return newSlabPool(bufferSize, slabCount, false)
+}
+
+// NewBlockingSlabPool is similar to NewSlabPool, but Get will wait until a buffer is put back or the ctx is done.
+// Get returns ErrPoolTimeout when the deadline of the ctx is exceeded.
+func NewBlockingSlabPool(bufferSize, slabCount int) Pool {
+	return newSlabPool(bufferSize, slabCount, true)
+}
+
+// NewBlockingSlabPoolWithTimeout is similar to NewBlockingSlabPool, but Get will wait at most timeout
+// and then return ErrPoolTimeout.
+func NewBlockingSlabPoolWithTimeout(bufferSize, slabCount int, timeout time.Duration) Pool {
+	p := newSlabPool(bufferSize, slabCount, true)
+	p.timeout = timeout
+	return p
+}
+
+func newSlabPool(bufferSize, slabCount int, blocking bool) *slabPool {
+	if bufferSize <= 0 {
+		bufferSize = DefaultBufferSize
+	}
+	if slabCount <= 0 {
+		slabCount = 1
+	}
+
+	p := &slabPool{
+		bufSize:  bufferSize,
+		blocking: blocking,
+		slab:     make([]byte, bufferSize*slabCount),
+		free:     make(chan *Buffer, slabCount),
+	}
+	for i := 0; i < slabCount; i++ {
+		start, end := i*bufferSize, (i+1)*bufferSize
+		// limit the capacity, so a buffer can not grow into its neighbour
+		p.free <- NewBuffer(p, p.slab[start:end:end])
+	}
+	return p
+}
+
+func (p *slabPool) BufferSize() int {
+	return p.bufSize
+}
+
+func (p *slabPool) Put(buf *Buffer) {
+	if buf == nil {
+		return
+	}
+	select {
+	case p.free <- buf:
+	default:
+		// the buffer does not belong to this pool
+	}
+}
+
+func (p *slabPool) Get(ctx context.Context) (*Buffer, error) {
+	if !p.blocking {
+		select {
+		case buf := <-p.free:
+			return buf, nil
+		default:
+			return nil, ErrNoBuffersAvailable
+		}
+	}
+
+	if p.timeout > 0 {
+		return p.GetWithTimeout(ctx, p.timeout)
+	}
+
+	select {
+	case buf := <-p.free:
+		return buf, nil
+	case <-ctx.Done():
+		return nil, poolCtxErr(ctx.Err())
+	}
+}
+
+// GetWithTimeout will wait at most d for a buffer to be put back, then return ErrPoolTimeout.
+func (p *slabPool) GetWithTimeout(ctx context.Context, d time.Duration) (*Buffer, error) {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case buf := <-p.free:
+		return buf, nil
+	case <-timer.C:
+		return nil, ErrPoolTimeout
+	case <-ctx.Done():
+		return nil, poolCtxErr(ctx.Err())
+	}
+}
+
+func poolCtxErr(err error) error {
+	if errors.Is(err, context.DeadlineExceeded) {
+		return &poolTimeoutError{err: err}
+	}
+	return err
+}
@@ -0,0 +1,138 @@
+package io
+
+import (
+	"context"
+	"errors"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSlabPool(t *testing.T) {
+	p := NewSlabPool(5, 2)
+	assert.EqualValues(t, 5, p.BufferSize())
+
+	buf1, err := p.Get(context.Background())
+	assert.NoError(t, err)
+	assert.Len(t, buf1.buffer, 5)
+	assert.EqualValues(t, 5, cap(buf1.buffer))
+
+	buf2, err := p.Get(context.Background())
+	assert.NoError(t, err)
+	assert.NotSame(t, buf1, buf2)
+
+	// exhausted
+	buf3, err := p.Get(context.Background())
+	assert.ErrorIs(t, err, ErrNoBuffersAvailable)
+	assert.Nil(t, buf3)
+
+	// the buffers must not overlap
+	copy(buf1.buffer, "12345")
+	copy(buf2.buffer, "67890")
+	assert.Equal(t, []byte("12345"), buf1.buffer)
+	assert.Equal(t, []byte("67890"), buf2.buffer)
+
+	// recycle
+	buf1.buffer = buf1.buffer[:0]
+	buf1.cleanUp()
+
+	buf3, err = p.Get(context.Background())
+	assert.NoError(t, err)
+	assert.Same(t, buf1, buf3)
+	assert.Len(t, buf3.buffer, 5)
+}
+
+func TestBlockingSlabPool(t *testing.T) {
+	p := NewBlockingSlabPool(5, 1)
+
+	buf1, err := p.Get(context.Background())
+	assert.NoError(t, err)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	buf2, err := p.Get(ctx)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.Nil(t, buf2)
+
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		buf1.cleanUp()
+	}()
+
+	buf2, err = p.Get(context.Background())
+	assert.NoError(t, err)
+	assert.Same(t, buf1, buf2)
+}
+
+func TestFlowSlabPool(t *testing.T) {
+	bf := NewBufferReadSeekCloserFactory(OptionWithPool(NewSlabPool(5, 2)))
+
+	brsc := bf.NewReader(&testReader{data: []byte("1234567890qwertyuiop")})
+	readBuf := make([]byte, 10)
+
+	n, err := brsc.Read(readBuf)
+	assert.NoError(t, err)
+	assert.EqualValues(t, 10, n)
+	assert.Equal(t, []byte("1234567890"), readBuf[:n])
+
+	seek, err := brsc.Seek(15, io.SeekStart)
+	assert.True(t, errors.Is(err, ErrNoBuffersAvailable))
+	assert.EqualValues(t, 10, seek)
+
+	// the buffers are recycled once the seeker is disabled
+	brsc.DisableSeeker()
+
+	n, err = brsc.Read(readBuf)
+	assert.NoError(t, err)
+	assert.EqualValues(t, 10, n)
+	assert.Equal(t, []byte("qwertyuiop"), readBuf[:n])
+
+	err = brsc.Close()
+	assert.NoError(t, err)
+
+	// all buffers are back to the pool
+	brsc = bf.NewReader(&testReader{data: []byte("1234567")})
+	defer brsc.Close()
+
+	seek, err = brsc.Seek(0, io.SeekEnd)
+	assert.NoError(t, err)
+	assert.EqualValues(t, 7, seek)
+}
+
+func TestBlockingSlabPoolTimeout(t *testing.T) {
+	p := NewBlockingSlabPool(5, 1)
+	buf1, err := p.Get(context.Background())
+	assert.NoError(t, err)
+
+	// the deadline of the ctx
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	_, err = p.Get(ctx)
+	assert.ErrorIs(t, err, ErrPoolTimeout)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+
+	_, err = p.(*slabPool).GetWithTimeout(context.Background(), 20*time.Millisecond)
+	assert.ErrorIs(t, err, ErrPoolTimeout)
+	buf1.cleanUp()
+
+	// the saturated pool is reported by the read
+	bf := NewBufferReadSeekCloserFactory(OptionWithPool(NewBlockingSlabPoolWithTimeout(5, 2, 20*time.Millisecond)))
+
+	brsc1 := bf.NewReader(&testReader{data: []byte("1234567890qwertyuiop")})
+	defer brsc1.Close()
+	_, err = brsc1.Seek(10, io.SeekStart)
+	assert.NoError(t, err)
+
+	brsc2 := bf.NewReader(&testReader{data: []byte("1234567890qwertyuiop")})
+	defer brsc2.Close()
+	_, err = brsc2.Read(make([]byte, 5))
+	assert.ErrorIs(t, err, ErrPoolTimeout)
+
+	// a buffer is put back
+	brsc1.DisableSeeker()
+	n, err := brsc2.Read(make([]byte, 5))
+	assert.NoError(t, err)
+	assert.EqualValues(t, 5, n)
+}
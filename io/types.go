@@ -4,21 +4,136 @@ import (
 	"context"
 	"errors"
 	"io"
+	"time"
 )
 
 const DefaultBufferSize = 32 * 1024
 
+const (
+	// MinSizeHintBufferSize is the smallest buffer size picked by NewReaderWithSizeHint
+	MinSizeHintBufferSize = 4 * 1024
+	// MaxSizeHintBufferSize is the largest buffer size picked by NewReaderWithSizeHint
+	MaxSizeHintBufferSize = 1024 * 1024
+	// sizeHintBuffers is the number of buffers NewReaderWithSizeHint is aiming for
+	sizeHintBuffers = 16
+)
+
 var (
 	ErrClosed              = errors.New("closed reader")
 	ErrSeekerDisabled      = errors.New("disabled seeker")
 	ErrSeekerOutOfRange    = errors.New("out of range")
 	ErrSeekerInvalidWhence = errors.New("invalid whence")
+	ErrBufferSizeChanged   = errors.New("pool buffer size changed")
+	ErrReaderAtUnsupported = errors.New("reader at is not supported")
+	ErrNoBuffersAvailable  = errors.New("no buffers available")
+	ErrSourceNotExhausted  = errors.New("source is not exhausted")
+	ErrNilReader           = errors.New("nil reader")
+	ErrMarkReleased        = errors.New("marked data is already released")
+	ErrUnexpectedStatus    = errors.New("unexpected http status")
+	// ErrPoolTimeout is returned by the blocking pool when no buffer is put back in time
+	ErrPoolTimeout = errors.New("pool timeout")
+	// ErrSourceRead wraps the unexpected error returned by the underlying reader while seeking,
+	// the other methods return the error of the underlying reader as is
+	ErrSourceRead = errors.New("source read error")
+	// ErrNotBuffered is returned by SeekBuffered when the target is not buffered yet
+	ErrNotBuffered = errors.New("seek target is not buffered")
+	// ErrSizeUnknown is returned by SeekFraction when the size of the stream is not known yet
+	ErrSizeUnknown = errors.New("size is unknown")
+	// ErrInternalState is returned instead of panicking when the buffers do not match the position, e.g. when
+	// the pool hands out buffers of an unexpected size
+	ErrInternalState = errors.New("internal state error")
+	// ErrResourceChanged is returned by the reader of NewHTTPRangeReader when the resource is modified
+	// between its requests, so the data of different versions are not mixed
+	ErrResourceChanged = errors.New("resource changed")
+	// ErrInvalidIVLength is returned by NewCTRDecryptReader when the iv does not match the block size
+	ErrInvalidIVLength = errors.New("invalid iv length")
 )
 
+type sourceReadError struct {
+	err error
+}
+
+func (e *sourceReadError) Error() string {
+	return ErrSourceRead.Error() + ": " + e.err.Error()
+}
+
+func (e *sourceReadError) Is(target error) bool {
+	return target == ErrSourceRead
+}
+
+func (e *sourceReadError) Unwrap() error {
+	return e.err
+}
+
+// poolTimeoutError is an ErrPoolTimeout caused by the deadline of the ctx
+type poolTimeoutError struct {
+	err error
+}
+
+func (e *poolTimeoutError) Error() string {
+	return ErrPoolTimeout.Error() + ": " + e.err.Error()
+}
+
+func (e *poolTimeoutError) Is(target error) bool {
+	return target == ErrPoolTimeout
+}
+
+func (e *poolTimeoutError) Unwrap() error {
+	return e.err
+}
+
+// drainCloseError holds both errors of DrainAndClose, errors.Is and errors.As match either of them
+type drainCloseError struct {
+	err      error
+	closeErr error
+}
+
+func (e *drainCloseError) Error() string {
+	return e.err.Error() + "; close: " + e.closeErr.Error()
+}
+
+func (e *drainCloseError) Is(target error) bool {
+	return errors.Is(e.err, target) || errors.Is(e.closeErr, target)
+}
+
+func (e *drainCloseError) As(target interface{}) bool {
+	return errors.As(e.err, target) || errors.As(e.closeErr, target)
+}
+
+func (e *drainCloseError) Unwrap() error {
+	return e.err
+}
+
 type BufferReadSeekCloserFactory interface {
 	// Close must be called in order to release the underlying buffer
 	NewReader(r io.Reader) BufferReadSeekCloser
+	// NewSeekableReader is similar to NewReader, but returns ErrNilReader if r can not be read at all
+	NewSeekableReader(r io.Reader) (BufferReadSeekCloser, error)
+	// NewReaderWithBufferSize is similar to NewReader, but the reader is using buffers of bufSize bytes
+	NewReaderWithBufferSize(r io.Reader, bufSize int) BufferReadSeekCloser
+	// NewReaderWithSizeHint is similar to NewReaderWithBufferSize, but the buffer size is picked from expectedSize,
+	// bounded between MinSizeHintBufferSize and MaxSizeHintBufferSize
+	NewReaderWithSizeHint(r io.Reader, expectedSize int64) BufferReadSeekCloser
 	BufferSize() int
+	// Config returns the effective settings of the factory
+	Config() FactoryConfig
+}
+
+// FactoryConfig is the effective settings of a BufferReadSeekCloserFactory, the zero value means the option is not set
+type FactoryConfig struct {
+	BufferSize int
+	// PoolType is the type name of the pool, e.g. "*io.pool"
+	PoolType           string
+	AutoRelease        bool
+	MaxReadSize        int
+	InlineThreshold    int
+	RetryAttempts      int
+	FillStats          bool
+	RingBuffers        int
+	FirstByteLatency   bool
+	ClampSeek          bool
+	IdleTimeout        time.Duration
+	KeepUnderlyingSeek bool
 }
 
 type BufferReadSeekCloser interface {
@@ -27,6 +142,151 @@ type BufferReadSeekCloser interface {
 	io.Closer
 	// DisableSeeker will disable the seeker function and release the underlying buffers
 	DisableSeeker()
+	// DisableSeekerE is similar to DisableSeeker but reports the transition.
+	// It returns ErrSeekerDisabled if the seeker is already disabled and ErrClosed if the reader is closed
+	DisableSeekerE() error
+	// SourceKind reports how the source was classified, e.g. SourceKindReadSeeker
+	SourceKind() string
+}
+
+// Source kinds reported by BufferReadSeekCloser.SourceKind
+const (
+	SourceKindReadSeeker           = "read-seeker"
+	SourceKindReadCloser           = "read-closer"
+	SourceKindReader               = "reader"
+	SourceKindBufferReadSeekCloser = "buffer-read-seek-closer"
+	SourceKindReaderAt             = "reader-at"
+	SourceKindSection              = "section"
+	SourceKindHTTPRange            = "http-range"
+	SourceKindNil                  = "nil"
+)
+
+// BufferDrainer is implemented by the BufferReadSeekCloser able to drain its remaining data
+type BufferDrainer interface {
+	// DrainTo will disable the seeker and write the data from the current position until EOF to w.
+	// The buffered data is written directly from the buffers, then the rest is streamed from the underlying reader
+	DrainTo(w io.Writer) (int64, error)
+}
+
+// BufferStreamer is implemented by the BufferReadSeekCloser able to forward its unconsumed data, e.g. for proxying
+type BufferStreamer interface {
+	// CopyBufferedThenStream is the same as BufferDrainer.DrainTo, the buffered data from the current position
+	// is written directly from the buffers, then the seeker is disabled and the rest is streamed from the underlying reader
+	CopyBufferedThenStream(w io.Writer) (int64, error)
+}
+
+// BufferAppender is implemented by the BufferReadSeekCloser able to extend its stream with another source
+type BufferAppender interface {
+	// Append will continue the stream with r once the current source reaches EOF
+	Append(r io.Reader) error
+}
+
+// ContextReader is implemented by the BufferReadSeekCloser able to abort a read when the ctx is done
+type ContextReader interface {
+	// ReadCtx is similar to Read, but returns ctx.Err() once the ctx is done.
+	// The aborted read keeps running in the background and its data stays buffered for the next read
+	ReadCtx(ctx context.Context, p []byte) (int, error)
+}
+
+// BufferRemainer is implemented by the BufferReadSeekCloser able to report the number of unread bytes
+type BufferRemainer interface {
+	// Remaining returns the number of bytes from the current position until EOF.
+	// It returns false when the total size is not known yet
+	Remaining() (int64, bool)
+}
+
+// BufferFlusher is implemented by the BufferReadSeekCloser able to hand over its buffered data when disabling the seeker
+type BufferFlusher interface {
+	// DisableSeekerFlush will write the buffered but unread data to w, then release the buffers and disable the seeker
+	DisableSeekerFlush(w io.Writer) error
+}
+
+// BufferMarker is implemented by the BufferReadSeekCloser able to return to a remembered position
+type BufferMarker interface {
+	// Mark will remember the current position, the data from the mark is kept buffered even with OptionWithAutoRelease,
+	// the data before the mark may be released. Only the latest mark is kept
+	Mark() (token int64, err error)
+	// Reset will seek back to the position of the token and consume the mark.
+	// It returns ErrMarkReleased if the data is already released
+	Reset(token int64) error
+}
+
+// Compacter is implemented by the BufferReadSeekCloser able to consolidate its retained buffers
+type Compacter interface {
+	// Compact will drop the released buffers from the buffer index and trim its spare capacity,
+	// the current position and the retained data are kept
+	Compact() error
+}
+
+// MemoryReporter is implemented by the BufferReadSeekCloser able to report its memory footprint
+type MemoryReporter interface {
+	// MemUsage returns the number of bytes currently retained in the buffers
+	MemUsage() int64
+}
+
+// SliceReader is implemented by the BufferReadSeekCloser able to read without copying
+type SliceReader interface {
+	// ReadSlice returns a view of at most n bytes of the buffered data from the current position and advances the
+	// position. The view aliases the internal buffer, it must not be modified and it is only valid until the reader is
+	// closed or its seeker is disabled. It may return less than n bytes even if more data are available
+	ReadSlice(n int) ([]byte, error)
+}
+
+// ForwardOnlyViewer is implemented by the BufferReadSeekCloser able to hand out a view without the seeker
+type ForwardOnlyViewer interface {
+	// ForwardOnly returns a view sharing the reader and its buffers, but only exposing Read and Close
+	ForwardOnly() io.ReadCloser
+}
+
+// SeekTracker is implemented by the BufferReadSeekCloser able to report how much data is buffered by a seek
+type SeekTracker interface {
+	// SeekTracked is similar to Seek, but it also returns the number of bytes read from the underlying reader
+	// during the seek. It is zero when the seek is served entirely from the buffer
+	SeekTracked(offset int64, whence int) (pos int64, bufferedBytes int64, err error)
+}
+
+// BufferedSeeker is implemented by the BufferReadSeekCloser able to seek without reading from the underlying reader
+type BufferedSeeker interface {
+	// SeekBuffered is similar to Seek, but it returns ErrNotBuffered instead of reading from the underlying reader
+	// when the target is not buffered yet
+	SeekBuffered(offset int64, whence int) (int64, error)
+}
+
+// SeekClamper is implemented by the BufferReadSeekCloser able to report the seek clamped by OptionWithClampSeek
+type SeekClamper interface {
+	// SeekClamped is similar to Seek, but it also reports whether the seek beyond the end is moved to the end
+	SeekClamped(offset int64, whence int) (pos int64, clamped bool, err error)
+}
+
+// FractionSeeker is implemented by the BufferReadSeekCloser able to seek to a fraction of the stream
+type FractionSeeker interface {
+	// SeekFraction will seek to the fraction f of the stream, f must be in [0, 1].
+	// It returns ErrSizeUnknown when the size is not reported by the source and the end is not reached yet
+	SeekFraction(f float64) (int64, error)
+}
+
+// Forker is implemented by the BufferReadSeekCloser able to split its remaining data into an independent reader
+type Forker interface {
+	// Fork will buffer the rest of the stream and return a new seekable reader over the data from the current position
+	// to the end. The position of the original reader is not changed. It requires the seeker to be enabled
+	Fork() (BufferReadSeekCloser, error)
+}
+
+// ReverseReader is implemented by the BufferReadSeekCloser able to read backward
+type ReverseReader interface {
+	// ReadReverse will fill p with the bytes preceding the current position in reverse order and move the position
+	// backward. The whole stream is buffered by the first call. It returns io.EOF at the start of the stream
+	ReadReverse(p []byte) (int, error)
+}
+
+// TailKeeper is implemented by the BufferReadSeekCloser able to keep a small tail once the seeker is disabled
+type TailKeeper interface {
+	// DisableSeekerKeepTail is similar to DisableSeekerE, but the last n bytes returned by Read are retained,
+	// so they can be read again after UnreadBytes. The data written by WriteTo is not retained
+	DisableSeekerKeepTail(n int) error
+	// UnreadBytes will move back n bytes, so they are returned again by the next Read.
+	// Once the seeker is disabled, it returns ErrSeekerDisabled if n exceeds the retained tail
+	UnreadBytes(n int) error
 }
 
 type Buffer struct {
@@ -52,3 +312,9 @@ type Pool interface {
 	Put(buf *Buffer)
 	Get(ctx context.Context) (*Buffer, error)
 }
+
+// DrainablePool is a Pool able to discard its idle buffers, so they can be reclaimed by the GC
+type DrainablePool interface {
+	Pool
+	Drain()
+}
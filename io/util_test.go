@@ -1,11 +1,113 @@
 package io
 
 import (
+	"bytes"
 	"context"
+	"errors"
 	"io"
+	"sync"
 	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
 )
 
+func TestPoolDrain(t *testing.T) {
+	p := newPool(5)
+	assert.EqualValues(t, 5, p.BufferSize())
+
+	buffers := make([]*Buffer, 0, 10)
+	for i := 0; i < 10; i++ {
+		buf, err := p.Get(context.Background())
+		assert.NoError(t, err)
+		assert.Len(t, buf.buffer, 5)
+		buffers = append(buffers, buf)
+	}
+	for _, buf := range buffers {
+		buf.cleanUp()
+	}
+
+	p.Drain()
+
+	for i := 0; i < 10; i++ {
+		buf, err := p.Get(context.Background())
+		assert.NoError(t, err)
+		for _, drained := range buffers {
+			assert.NotSame(t, drained, buf)
+		}
+	}
+
+	// concurrent usage
+	wg := sync.WaitGroup{}
+	for i := 0; i < 10; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				buf, err := p.Get(context.Background())
+				assert.NoError(t, err)
+				buf.cleanUp()
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 10; j++ {
+				p.Drain()
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+func TestPoolOnAllocate(t *testing.T) {
+	var allocations int32
+	p := NewPoolWithOnAllocate(5, func() {
+		atomic.AddInt32(&allocations, 1)
+	})
+
+	// empty pool
+	buf, err := p.Get(context.Background())
+	assert.NoError(t, err)
+	assert.EqualValues(t, 1, atomic.LoadInt32(&allocations))
+
+	// sync.Pool may drop the idle buffer, e.g. with the race detector, so the reuse is retried
+	reused := false
+	for i := 0; i < 100 && !reused; i++ {
+		before := atomic.LoadInt32(&allocations)
+		buf.cleanUp()
+		var next *Buffer
+		next, err = p.Get(context.Background())
+		assert.NoError(t, err)
+
+		reused = next == buf
+		if reused {
+			assert.Equal(t, before, atomic.LoadInt32(&allocations))
+		} else {
+			assert.Equal(t, before+1, atomic.LoadInt32(&allocations))
+		}
+		buf = next
+	}
+	assert.True(t, reused)
+}
+
+func TestTrackingPool(t *testing.T) {
+	p, outstanding := NewTrackingPool(5)
+	assert.EqualValues(t, 5, p.BufferSize())
+	bf := NewBufferReadSeekCloserFactory(OptionWithPool(p))
+
+	brsc := bf.NewReader(&testReader{data: []byte("1234567890qwertyuiop")})
+	_, err := brsc.Seek(12, io.SeekStart)
+	assert.NoError(t, err)
+
+	// the reader is not closed yet, so the buffers are leaked
+	assert.EqualValues(t, 3, outstanding())
+
+	err = brsc.Close()
+	assert.NoError(t, err)
+	assert.EqualValues(t, 0, outstanding())
+}
+
 type testReadSeekCloser struct {
 	readSeeker io.ReadSeeker
 }
@@ -51,6 +153,23 @@ func (p *noPool) Get(ctx context.Context) (*Buffer, error) {
 	return NewBuffer(p, make([]byte, p.bufSize)), nil
 }
 
+// shortPool reports bufSize, but hands out buffers of shortSize, which corrupts the index math of the reader
+type shortPool struct {
+	bufSize   int
+	shortSize int
+}
+
+func (p *shortPool) BufferSize() int {
+	return p.bufSize
+}
+
+func (p *shortPool) Put(buf *Buffer) {
+}
+
+func (p *shortPool) Get(ctx context.Context) (*Buffer, error) {
+	return NewBuffer(p, make([]byte, p.shortSize)), nil
+}
+
 type testPool struct {
 	diff int32
 	p    Pool
@@ -78,3 +197,186 @@ func (t *testPool) Get(ctx context.Context) (*Buffer, error) {
 	buf.pool = t
 	return buf, nil
 }
+
+type mutableSizePool struct {
+	bufSize int32
+	p       Pool
+}
+
+func (m *mutableSizePool) SetBufferSize(size int) {
+	atomic.StoreInt32(&m.bufSize, int32(size))
+}
+
+func (m *mutableSizePool) BufferSize() int {
+	return int(atomic.LoadInt32(&m.bufSize))
+}
+
+func (m *mutableSizePool) Put(buf *Buffer) {
+	m.p.Put(buf)
+}
+
+func (m *mutableSizePool) Get(ctx context.Context) (*Buffer, error) {
+	return m.p.Get(ctx)
+}
+
+type testWriterToReader struct {
+	r *bytes.Reader
+}
+
+func (t *testWriterToReader) Read(p []byte) (n int, err error) {
+	return t.r.Read(p)
+}
+
+func (t *testWriterToReader) WriteTo(w io.Writer) (n int64, err error) {
+	return t.r.WriteTo(w)
+}
+
+type testRecordReader struct {
+	r          io.Reader
+	maxReadLen int
+}
+
+func (t *testRecordReader) Read(p []byte) (n int, err error) {
+	if len(p) > t.maxReadLen {
+		t.maxReadLen = len(p)
+	}
+	return t.r.Read(p)
+}
+
+type testErrReader struct {
+	data  []byte
+	pos   int64
+	errAt int64
+	err   error
+}
+
+// Read will return err once the position reaches errAt
+func (t *testErrReader) Read(p []byte) (n int, err error) {
+	if t.pos >= t.errAt {
+		return 0, t.err
+	}
+	n = copy(p, t.data[t.pos:t.errAt])
+	t.pos += int64(n)
+	return
+}
+
+type testSizedReader struct {
+	testReader
+}
+
+func (t *testSizedReader) Len() int {
+	return len(t.data) - int(t.pos)
+}
+
+// testGrowingReader reports fewer unread bytes than it has, like a source still being written
+type testGrowingReader struct {
+	testReader
+	reported int
+}
+
+func (t *testGrowingReader) Len() int {
+	return t.reported
+}
+
+type testDataEOFReader struct {
+	data []byte
+	pos  int64
+	n    int64
+}
+
+// Read will return at most n bytes per call, the last data is returned along with io.EOF
+func (t *testDataEOFReader) Read(p []byte) (n int, err error) {
+	if t.pos >= int64(len(t.data)) {
+		return 0, io.EOF
+	}
+	if int64(len(p)) > t.n {
+		p = p[:t.n]
+	}
+	n = copy(p, t.data[t.pos:])
+	t.pos += int64(n)
+	if t.pos >= int64(len(t.data)) {
+		err = io.EOF
+	}
+	return
+}
+
+type testDelayedReader struct {
+	r       io.Reader
+	delay   time.Duration
+	delayed bool
+}
+
+// Read will sleep for the delay before the first read
+func (t *testDelayedReader) Read(p []byte) (n int, err error) {
+	if !t.delayed {
+		t.delayed = true
+		time.Sleep(t.delay)
+	}
+	return t.r.Read(p)
+}
+
+type testSlowReader struct {
+	r       io.Reader
+	release chan struct{}
+}
+
+// Read will block until the release is closed
+func (t *testSlowReader) Read(p []byte) (n int, err error) {
+	<-t.release
+	return t.r.Read(p)
+}
+
+type testFlakyReader struct {
+	r        io.Reader
+	failures int
+	err      error
+}
+
+// Read will return err for the first failures calls
+func (t *testFlakyReader) Read(p []byte) (n int, err error) {
+	if t.failures > 0 {
+		t.failures--
+		return 0, t.err
+	}
+	return t.r.Read(p)
+}
+
+func TestDrainAndClose(t *testing.T) {
+	tp := &testPool{p: newPool(5)}
+	bf := NewBufferReadSeekCloserFactory(OptionWithPool(tp))
+
+	source := &testReader{data: []byte("1234567890qwertyuiop")}
+	brsc := bf.NewReader(source)
+
+	_, err := brsc.Read(make([]byte, 3))
+	assert.NoError(t, err)
+
+	err = DrainAndClose(brsc)
+	assert.NoError(t, err)
+	assert.EqualValues(t, len(source.data), source.pos)
+	assert.EqualValues(t, 0, tp.Diff())
+
+	_, err = brsc.Read(make([]byte, 3))
+	assert.ErrorIs(t, err, ErrClosed)
+
+	// both the drain and the close fail
+	readErr, closeErr := errors.New("read failed"), errors.New("close failed")
+	brsc = bf.NewReader(&testFailingSource{readErr: readErr, closeErr: closeErr})
+	err = DrainAndClose(brsc)
+	assert.ErrorIs(t, err, readErr)
+	assert.ErrorIs(t, err, closeErr)
+	assert.EqualValues(t, 0, tp.Diff())
+}
+
+type testFailingSource struct {
+	readErr  error
+	closeErr error
+}
+
+func (t *testFailingSource) Read(p []byte) (int, error) {
+	return 0, t.readErr
+}
+
+func (t *testFailingSource) Close() error {
+	return t.closeErr
+}
@@ -3,7 +3,9 @@ package io
 import (
 	"context"
 	"io"
+	"reflect"
 	"sync"
+	"sync/atomic"
 )
 
 var (
@@ -12,31 +14,180 @@ var (
 )
 
 type pool struct {
-	p       *sync.Pool
-	bufSize int
+	p          atomic.Value // *sync.Pool
+	bufSize    int
+	onAllocate func()
 }
 
-func newPool(bufferSize int) Pool {
+func newPool(bufferSize int) DrainablePool {
+	return NewPoolWithOnAllocate(bufferSize, nil)
+}
+
+// NewPoolWithOnAllocate is similar to the default pool, but the onAllocate is called whenever a brand-new buffer
+// is created instead of reusing an idle one, so the hit rate can be derived from the number of Get
+func NewPoolWithOnAllocate(bufferSize int, onAllocate func()) DrainablePool {
 	if bufferSize <= 0 {
 		bufferSize = DefaultBufferSize
 	}
 	p := &pool{
-		bufSize: bufferSize,
+		bufSize:    bufferSize,
+		onAllocate: onAllocate,
 	}
-	p.p = &sync.Pool{New: func() interface{} {
-		return NewBuffer(p, make([]byte, bufferSize))
-	}}
+	p.Drain()
 	return p
 }
 
+func (p *pool) syncPool() *sync.Pool {
+	return p.p.Load().(*sync.Pool)
+}
+
+// Drain will discard the idle buffers. It is safe to be called concurrently with Get and Put
+func (p *pool) Drain() {
+	p.p.Store(&sync.Pool{New: func() interface{} {
+		if p.onAllocate != nil {
+			p.onAllocate()
+		}
+		return NewBuffer(p, make([]byte, p.bufSize))
+	}})
+}
+
 func (p *pool) BufferSize() int {
 	return p.bufSize
 }
 
 func (p *pool) Put(buf *Buffer) {
-	p.p.Put(buf)
+	p.syncPool().Put(buf)
 }
 
 func (p *pool) Get(ctx context.Context) (*Buffer, error) {
-	return p.p.Get().(*Buffer), nil
+	return p.syncPool().Get().(*Buffer), nil
+}
+
+// discardPool owns the buffers which are not backed by any pool, e.g. the inline buffer
+type discardPool struct{}
+
+func (discardPool) BufferSize() int {
+	return 0
+}
+
+func (discardPool) Put(buf *Buffer) {}
+
+func (discardPool) Get(ctx context.Context) (*Buffer, error) {
+	return nil, ErrNoBuffersAvailable
+}
+
+// DrainAndClose will discard the remaining data of r and close it, so the connection behind an HTTP body can be reused.
+// The seeker is disabled first, so the drained data is not buffered. When both the drain and the close fail,
+// the returned error matches both of them by errors.Is.
+func DrainAndClose(r BufferReadSeekCloser) error {
+	r.DisableSeeker()
+	_, err := io.Copy(Discard, r)
+	closeErr := r.Close()
+	switch {
+	case err == nil:
+		return closeErr
+	case closeErr != nil:
+		return &drainCloseError{err: err, closeErr: closeErr}
+	}
+	return err
+}
+
+// forwardOnly hides every method of rc except Read and Close
+type forwardOnly struct {
+	rc io.ReadCloser
+}
+
+func (f *forwardOnly) Read(p []byte) (int, error) {
+	return f.rc.Read(p)
+}
+
+func (f *forwardOnly) Close() error {
+	return f.rc.Close()
+}
+
+// nilReader is created for a nil reader, so the misuse is reported by ErrNilReader instead of a nil pointer panic
+type nilReader struct {
+	isClosed int32
+}
+
+func (n *nilReader) Read(p []byte) (int, error) {
+	if atomic.LoadInt32(&n.isClosed) == 1 {
+		return 0, ErrClosed
+	}
+	return 0, ErrNilReader
+}
+
+func (n *nilReader) Seek(offset int64, whence int) (int64, error) {
+	if atomic.LoadInt32(&n.isClosed) == 1 {
+		return 0, ErrClosed
+	}
+	return 0, ErrNilReader
+}
+
+func (n *nilReader) Close() error {
+	if !atomic.CompareAndSwapInt32(&n.isClosed, 0, 1) {
+		return ErrClosed
+	}
+	return nil
+}
+
+func (n *nilReader) DisableSeeker() {
+	_ = n.DisableSeekerE()
+}
+
+func (n *nilReader) SourceKind() string {
+	return SourceKindNil
+}
+
+func (n *nilReader) DisableSeekerE() error {
+	if atomic.LoadInt32(&n.isClosed) == 1 {
+		return ErrClosed
+	}
+	return nil
+}
+
+func isNilReader(r io.Reader) bool {
+	if r == nil {
+		return true
+	}
+	switch v := reflect.ValueOf(r); v.Kind() {
+	case reflect.Ptr, reflect.Interface, reflect.Map, reflect.Slice, reflect.Func, reflect.Chan:
+		return v.IsNil()
+	default:
+		return false
+	}
+}
+
+type trackingPool struct {
+	outstanding int32
+	p           Pool
+}
+
+// NewTrackingPool will create a Pool reporting the number of outstanding buffers, it is useful to detect buffer leaks in tests.
+func NewTrackingPool(bufferSize int) (Pool, func() int32) {
+	p := &trackingPool{p: newPool(bufferSize)}
+	return p, p.Outstanding
+}
+
+func (t *trackingPool) Outstanding() int32 {
+	return atomic.LoadInt32(&t.outstanding)
+}
+
+func (t *trackingPool) BufferSize() int {
+	return t.p.BufferSize()
+}
+
+func (t *trackingPool) Put(buf *Buffer) {
+	atomic.AddInt32(&t.outstanding, -1)
+	t.p.Put(buf)
+}
+
+func (t *trackingPool) Get(ctx context.Context) (*Buffer, error) {
+	buf, err := t.p.Get(ctx)
+	if err != nil {
+		return nil, err
+	}
+	atomic.AddInt32(&t.outstanding, 1)
+	buf.pool = t
+	return buf, nil
 }
@@ -2,31 +2,131 @@ package wrapper
 
 import (
 	"context"
+	cryptorand "crypto/rand"
+	"encoding/hex"
 	"errors"
+	"fmt"
+	"math/rand"
 	"reflect"
+	"runtime/debug"
+	"sort"
 	"sync"
 	"sync/atomic"
+	"time"
 )
 
 var (
 	ErrAlreadyShutdown = errors.New("already shutdown")
+	ErrKeyExists       = errors.New("key already exists")
+	ErrGoroutineLeak   = errors.New("goroutines are still running")
 )
 
 type HandleFunc func(ctx context.Context, wrapperData *Data)
 
+// ErrHandleFunc is a HandleFunc reporting its error
+type ErrHandleFunc func(ctx context.Context, wrapperData *Data) error
+
 type Option func(wrapperData *Data)
 
+// Middleware wraps the next HandleFunc. It may call the next with a ctx derived from its own ctx,
+// e.g. by context.WithValue, and the derived ctx is seen by all inner middlewares and the fn
 type Middleware func(next HandleFunc) HandleFunc
 
+// ScheduleFunc is the submit step of RunAsync, it is called before the goroutine is spawned
+type ScheduleFunc func(ctx context.Context, fn HandleFunc, opts ...Option)
+
+// SchedulerMiddleware wraps the submit step of RunAsync, e.g. for admission control.
+// Not calling the next will drop the fn
+type SchedulerMiddleware func(next ScheduleFunc) ScheduleFunc
+
 type FuncManager interface {
 	// Run will run the fn synchronously
 	Run(ctx context.Context, fn HandleFunc, opts ...Option)
 	// RunAsync will run the fn inside goroutine. No need to spawn the goroutine
 	RunAsync(ctx context.Context, fn HandleFunc, opts ...Option)
+	// RunEvery will run the fn periodically inside goroutine until the ctx is done or the manager is shutdown.
+	// The interval is counted after the previous run is done
+	RunEvery(ctx context.Context, interval time.Duration, fn HandleFunc, opts ...Option)
+	// RunE will run the fn synchronously and return its error, including the error set by the middlewares.
+	// It returns ErrAlreadyShutdown when the manager is shutdown
+	RunE(ctx context.Context, fn ErrHandleFunc, opts ...Option) error
+	// RunAllE will run the fns concurrently and wait for all of them. The shared ctx is cancelled on the first error,
+	// which is returned. It returns ErrAlreadyShutdown when the manager is shutdown
+	RunAllE(ctx context.Context, fns ...ErrHandleFunc) error
+	// RunBatch will run the fns concurrently with the same opts and wait for all of them
+	RunBatch(ctx context.Context, fns []HandleFunc, opts ...Option)
+	// RunAsyncBatch will submit the fns with the same opts, each of them is run as by RunAsync
+	RunAsyncBatch(ctx context.Context, fns []HandleFunc, opts ...Option)
+	// Child will create a manager inheriting the middlewares, followed by the given middlewares.
+	// The child shares the MaxConcurrency and the Workers of this manager, and it inherits the Schedulers,
+	// the OnRejected and the DefaultIdentifier. The OnShutdownDrain and the MaxLifetime are not inherited,
+	// the child is shutdown along with this manager, but it can also be shutdown independently
+	Child(middlewares ...Middleware) FuncManager
+	// Pause will hold the new runs until Resume is called, the running functions are not affected.
+	// The held runs are cancelled when the manager is shutdown
+	Pause()
+	// Resume will release the runs held by Pause
+	Resume()
+	// Middlewares will return the names of the middlewares in the execution order, the outermost first.
+	// The name is set by NamedMiddleware, it is empty for the other middlewares
+	Middlewares() []string
+	// SetMiddlewares will replace the middlewares of this manager. It only applies to the runs started after the call,
+	// the started runs keep their chain. The children keep the middlewares inherited when they are created
+	SetMiddlewares(middlewares ...Middleware)
+	// Running will return the number of functions currently executing, the held and the queued runs are not counted
+	Running() int
+	// PeakConcurrency will return the highest Running reached over the manager's lifetime
+	PeakConcurrency() int
+	// Stats will return a snapshot of the manager
+	Stats() ManagerStats
 	// Wait will wait for the func manager is shutdown
 	Wait() <-chan struct{}
-	// Shutdown will force shutdown when the ctx is done
+	// WaitCause will wait for the func manager is shutdown and return the cause.
+	// It returns nil when all functions are drained, otherwise the error of the Shutdown ctx
+	WaitCause() error
+	// StopAccepting will reject the new runs of this manager and its children, the running functions are not affected.
+	// It is the first phase of a two-phase shutdown, see Drain
+	StopAccepting()
+	// Drain will wait for the running functions of this manager and its children until the ctx is done,
+	// without cancelling them. It returns the error of the ctx when the functions are not drained in time.
+	// Once drained after StopAccepting, the manager is shutdown gracefully, so Wait and WaitCause are released
+	Drain(ctx context.Context) error
+	// Shutdown will force shutdown when the ctx is done. The RunAsync jobs still queued for the workers are not run,
+	// they are rejected with the error of the ctx
 	Shutdown(ctx context.Context) error
+	// ShutdownReport is similar to Shutdown, but it also returns the sorted identifiers of the functions still running
+	// when the ctx is done. Functions without identifier are not reported
+	ShutdownReport(ctx context.Context) ([]string, error)
+}
+
+// ShutdownMode reports how the manager is shut down
+type ShutdownMode int
+
+const (
+	// ShutdownModeNone means the manager is not shut down yet
+	ShutdownModeNone ShutdownMode = iota
+	// ShutdownModeGraceful means all functions are drained before the Shutdown ctx is done
+	ShutdownModeGraceful
+	// ShutdownModeForced means the Shutdown ctx is done before the functions are drained
+	ShutdownModeForced
+)
+
+func (s ShutdownMode) String() string {
+	switch s {
+	case ShutdownModeGraceful:
+		return "graceful"
+	case ShutdownModeForced:
+		return "forced"
+	default:
+		return "none"
+	}
+}
+
+// ManagerStats is a snapshot of the FuncManager
+type ManagerStats struct {
+	Running         int
+	PeakConcurrency int
+	ShutdownMode    ShutdownMode
 }
 
 type Data struct {
@@ -44,25 +144,86 @@ func (d *Data) Get(key interface{}) interface{} {
 }
 
 func (d *Data) Set(key interface{}, val interface{}) error {
-	if key == nil {
-		return errors.New("nil key")
+	if err := validateKey(key); err != nil {
+		return err
 	}
-	if !reflect.TypeOf(key).Comparable() {
-		return errors.New("key is not comparable")
+	d.dataLock.Lock()
+	defer d.dataLock.Unlock()
+	if d.data == nil {
+		d.data = make(map[interface{}]interface{})
+	}
+	d.data[key] = val
+	return nil
+}
+
+// SetOnce is similar to Set, but returns ErrKeyExists if the key is already set
+func (d *Data) SetOnce(key interface{}, val interface{}) error {
+	if err := validateKey(key); err != nil {
+		return err
+	}
+	d.dataLock.Lock()
+	defer d.dataLock.Unlock()
+	if d.data == nil {
+		d.data = make(map[interface{}]interface{})
+	}
+	if _, ok := d.data[key]; ok {
+		return ErrKeyExists
+	}
+	d.data[key] = val
+	return nil
+}
+
+// GetOrSet will return the value of the key, or set it to the result of the factory if the key is absent.
+// The factory is called under the lock, so it is called once for the concurrent calls and must not use the Data
+func (d *Data) GetOrSet(key interface{}, factory func() interface{}) (interface{}, error) {
+	if err := validateKey(key); err != nil {
+		return nil, err
 	}
 	d.dataLock.Lock()
 	defer d.dataLock.Unlock()
 	if d.data == nil {
 		d.data = make(map[interface{}]interface{})
 	}
+	if val, ok := d.data[key]; ok {
+		return val, nil
+	}
+	var val interface{}
+	if factory != nil {
+		val = factory()
+	}
 	d.data[key] = val
+	return val, nil
+}
+
+func validateKey(key interface{}) error {
+	if key == nil {
+		return errors.New("nil key")
+	}
+	if !reflect.TypeOf(key).Comparable() {
+		return errors.New("key is not comparable")
+	}
 	return nil
 }
 
 type key string
 
 const (
-	keyIdentifier = key("identifier")
+	keyIdentifier      = key("identifier")
+	keyNoShutdownWatch = key("no-shutdown-watch")
+	keyTypedKey        = key("typed-key")
+	keyContextValues   = key("context-values")
+	keySkipReason      = key("skip-reason")
+	keyWeight          = key("weight")
+	keyData            = key("data")
+	keyJitter          = key("jitter")
+	keyError           = key("error")
+	keyStartTime       = key("start-time")
+	keyResultChannel   = key("result-channel")
+	keyResult          = key("result")
+	keyOnComplete      = key("on-complete")
+	keyTraceID         = key("trace-id")
+	keyTraceIDEnabled  = key("trace-id-enabled")
+	keyCoalesced       = key("coalesced")
 )
 
 func WithOptionIdentifier(funcName string) Option {
@@ -79,14 +240,188 @@ func GetIdentifier(wrapperData *Data) string {
 	return val
 }
 
+// DataFromContext will return the Data of the run carrying the ctx, or nil if the ctx is not created by the FuncManager
+func DataFromContext(ctx context.Context) *Data {
+	if ctx == nil {
+		return nil
+	}
+	data, _ := ctx.Value(keyData).(*Data)
+	return data
+}
+
+// Skip will mark the run as skipped, it is used by the middleware deciding not to call the next
+func Skip(wrapperData *Data, reason string) {
+	_ = wrapperData.Set(keySkipReason, reason)
+}
+
+// WasSkipped will return whether the run is marked as skipped and the reason
+func WasSkipped(wrapperData *Data) (bool, string) {
+	reason, ok := wrapperData.Get(keySkipReason).(string)
+	return ok, reason
+}
+
+// WithOptionWeight will set the weight of the fn in a manager with Config.MaxConcurrency.
+// The weight defaults to 1, a weight less than 1 is ignored and a weight greater than the MaxConcurrency is capped.
+func WithOptionWeight(w int) Option {
+	return func(data *Data) {
+		if w < 1 {
+			return
+		}
+		_ = data.Set(keyWeight, w)
+	}
+}
+
+// WithInitialData will set the entries of the m into the Data of the run. The invalid keys are skipped, see Data.Set
+func WithInitialData(m map[interface{}]interface{}) Option {
+	return func(data *Data) {
+		for key, val := range m {
+			_ = data.Set(key, val)
+		}
+	}
+}
+
+// WithOptionJitter will add a random delay in the range of [0, max] to each interval of RunEvery
+func WithOptionJitter(max time.Duration) Option {
+	return func(data *Data) {
+		if max <= 0 {
+			return
+		}
+		_ = data.Set(keyJitter, max)
+	}
+}
+
+// WithOptionStartTime will record the time when the run is started, see Elapsed
+func WithOptionStartTime() Option {
+	return func(data *Data) {
+		_ = data.Set(keyStartTime, time.Now())
+	}
+}
+
+// Elapsed will return the duration since the run is started, it returns false without WithOptionStartTime
+func Elapsed(wrapperData *Data) (time.Duration, bool) {
+	startTime, ok := wrapperData.Get(keyStartTime).(time.Time)
+	if !ok {
+		return 0, false
+	}
+	return time.Since(startTime), true
+}
+
+// WithOptionResultChannel will send the result published by SetResult to the ch once the run is done.
+// A nil is sent when the result is not set, the fn panics or the fn is not executed at all.
+// The send is dropped when nobody receives it before the ctx of the run is done or the manager is shutdown
+func WithOptionResultChannel(ch chan<- interface{}) Option {
+	return func(data *Data) {
+		if ch == nil {
+			return
+		}
+		_ = data.Set(keyResultChannel, ch)
+	}
+}
+
+// SetResult will publish the result of the run, see WithOptionResultChannel
+func SetResult(wrapperData *Data, v interface{}) {
+	_ = wrapperData.Set(keyResult, v)
+}
+
+// WithOptionOnComplete will call the cb once the fn is done, panicked reports whether the fn panics.
+// The panic is not recovered. The cb is not called when the fn is not executed at all
+func WithOptionOnComplete(cb func(data *Data, panicked bool)) Option {
+	return func(data *Data) {
+		if cb == nil {
+			return
+		}
+		_ = data.Set(keyOnComplete, cb)
+	}
+}
+
+// WithOptionTraceID will tag the run with a trace ID, which is also stored in the ctx of the fn.
+// The trace ID of the ctx passed to the run is reused, so the nested runs share it, otherwise a random one is generated.
+// See TraceID and TraceIDFromContext
+func WithOptionTraceID() Option {
+	return func(data *Data) {
+		_ = data.Set(keyTraceIDEnabled, true)
+	}
+}
+
+// TraceID will return the trace ID set by WithOptionTraceID, or empty if it is not set
+func TraceID(wrapperData *Data) string {
+	traceID, _ := wrapperData.Get(keyTraceID).(string)
+	return traceID
+}
+
+// TraceIDFromContext will return the trace ID of the run carrying the ctx, or empty if it is not set
+func TraceIDFromContext(ctx context.Context) string {
+	if ctx == nil {
+		return ""
+	}
+	traceID, _ := ctx.Value(keyTraceID).(string)
+	return traceID
+}
+
+// withTraceID will store the trace ID of the run into its Data and ctx, see WithOptionTraceID
+func withTraceID(ctx context.Context, wrapperData *Data) context.Context {
+	if enabled, _ := wrapperData.Get(keyTraceIDEnabled).(bool); !enabled {
+		return ctx
+	}
+	traceID := TraceIDFromContext(ctx)
+	if traceID == "" {
+		traceID = newTraceID()
+	}
+	_ = wrapperData.Set(keyTraceID, traceID)
+	return context.WithValue(ctx, keyTraceID, traceID)
+}
+
+func newTraceID() string {
+	b := make([]byte, 16)
+	// crypto/rand only fails when the entropy source of the OS is unavailable
+	_, _ = cryptorand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// randInt63n is replaceable for testing
+var randInt63n = rand.Int63n
+
+// WithOptionNoShutdownWatch will skip spawning the goroutine that watches the manager shutdown.
+// It is intended for short synchronous functions, the fn will not be cancelled when the manager is shutting down.
+func WithOptionNoShutdownWatch() Option {
+	return func(data *Data) {
+		_ = data.Set(keyNoShutdownWatch, true)
+	}
+}
+
+// WithOptionContextValues will seed the ctx of the fn with the key-value pairs.
+// Pairs having a nil or non comparable key are skipped.
+func WithOptionContextValues(kv ...[2]interface{}) Option {
+	return func(data *Data) {
+		values, _ := data.Get(keyContextValues).([][2]interface{})
+		for _, pair := range kv {
+			if pair[0] == nil || !reflect.TypeOf(pair[0]).Comparable() {
+				continue
+			}
+			values = append(values, pair)
+		}
+		_ = data.Set(keyContextValues, values)
+	}
+}
+
 func WithMiddlewareRecoverPanic(onPanic func(recoverVal interface{}, wrapperData *Data)) Middleware {
+	if onPanic == nil {
+		return WithMiddlewareRecoverPanicCtx(nil)
+	}
+	return WithMiddlewareRecoverPanicCtx(func(ctx context.Context, recoverVal interface{}, wrapperData *Data) {
+		onPanic(recoverVal, wrapperData)
+	})
+}
+
+// WithMiddlewareRecoverPanicCtx is similar to WithMiddlewareRecoverPanic, but the onPanic also receives the ctx of the fn
+func WithMiddlewareRecoverPanicCtx(onPanic func(ctx context.Context, recoverVal interface{}, wrapperData *Data)) Middleware {
 	return func(next HandleFunc) HandleFunc {
 		return func(ctx context.Context, wrapperData *Data) {
 			defer func() {
 				val := recover()
 				if val != nil {
 					if onPanic != nil {
-						onPanic(val, wrapperData)
+						onPanic(ctx, val, wrapperData)
 					}
 				}
 			}()
@@ -95,113 +430,1167 @@ func WithMiddlewareRecoverPanic(onPanic func(recoverVal interface{}, wrapperData
 	}
 }
 
-type funcManager struct {
-	wg            sync.WaitGroup
-	isShutdown    int32
-	shutdown      chan struct{}
-	mainCtx       context.Context
-	mainCtxCancel context.CancelFunc
-	middlewares   []Middleware
+// WithMiddlewarePanicToError will recover the panic and convert it into the error returned by RunE and RunAllE.
+// The convert receives the recovered value and the stack trace, a nil convert formats the recovered value.
+func WithMiddlewarePanicToError(convert func(recoverVal interface{}, stack []byte) error) Middleware {
+	return func(next HandleFunc) HandleFunc {
+		return func(ctx context.Context, wrapperData *Data) {
+			defer func() {
+				val := recover()
+				if val == nil {
+					return
+				}
+				var err error
+				if convert != nil {
+					err = convert(val, debug.Stack())
+				} else {
+					err = fmt.Errorf("panic: %v", val)
+				}
+				if err != nil {
+					_ = wrapperData.Set(keyError, err)
+				}
+			}()
+			next(ctx, wrapperData)
+		}
+	}
 }
 
-func NewFuncManager(middlewares ...Middleware) FuncManager {
-	ctx, cancel := context.WithCancel(context.Background())
-
-	m := &funcManager{
-		shutdown:      make(chan struct{}),
-		mainCtx:       ctx,
-		mainCtxCancel: cancel,
-		middlewares:   middlewares,
+// NamedMiddleware will name the mw, so it is reported by FuncManager.Middlewares.
+// The returned Middleware can be used wherever a Middleware is accepted
+func NamedMiddleware(name string, mw Middleware) Middleware {
+	return func(next HandleFunc) HandleFunc {
+		if next == nil && atomic.LoadInt32(&namingMiddleware) == 1 {
+			namedMiddlewareName = name
+			return nil
+		}
+		if mw == nil {
+			return next
+		}
+		return mw(next)
 	}
+}
 
-	return m
+var (
+	// namingMu guards the naming, the NamedMiddleware built with a nil next during the naming reports its name
+	namingMu            sync.Mutex
+	namingMiddleware    int32
+	namedMiddlewareName string
+)
+
+// namedMiddleware is a Middleware along with the name set by NamedMiddleware
+type namedMiddleware struct {
+	name       string
+	middleware Middleware
 }
 
-func (m *funcManager) Run(ctx context.Context, fn HandleFunc, opts ...Option) {
-	if atomic.LoadInt32(&m.isShutdown) == 1 {
-		return
+// nameMiddlewares will resolve the names of the middlewares once they are set, so the runs do not pay for it.
+// The middlewares are only built with a nil next, they are never executed
+func nameMiddlewares(middlewares []Middleware) []namedMiddleware {
+	namingMu.Lock()
+	defer namingMu.Unlock()
+
+	atomic.StoreInt32(&namingMiddleware, 1)
+	defer atomic.StoreInt32(&namingMiddleware, 0)
+
+	named := make([]namedMiddleware, 0, len(middlewares))
+	for _, mw := range middlewares {
+		namedMiddlewareName = ""
+		if mw != nil {
+			_ = mw(nil)
+		}
+		named = append(named, namedMiddleware{name: namedMiddlewareName, middleware: mw})
 	}
+	return named
+}
 
-	m.wg.Add(1)
-	defer m.wg.Done()
-	m.run(ctx, fn, opts...)
+// WithValueMiddleware will inject the val under the key into the ctx seen by the inner middlewares and the fn.
+// The run is passed through when the key is nil or not comparable
+func WithValueMiddleware(key, val interface{}) Middleware {
+	if validateKey(key) != nil {
+		return func(next HandleFunc) HandleFunc {
+			return next
+		}
+	}
+	return func(next HandleFunc) HandleFunc {
+		return func(ctx context.Context, wrapperData *Data) {
+			next(context.WithValue(ctx, key, val), wrapperData)
+		}
+	}
 }
 
-func (m *funcManager) RunAsync(ctx context.Context, fn HandleFunc, opts ...Option) {
-	if atomic.LoadInt32(&m.isShutdown) == 1 {
-		return
+// WithMiddlewareContextTimeout will apply the time.Duration stored in the Data at the key as the timeout of the ctx
+// seen by the inner middlewares and the fn. The run is passed through when the key is absent or not a time.Duration
+func WithMiddlewareContextTimeout(key interface{}) Middleware {
+	return func(next HandleFunc) HandleFunc {
+		return func(ctx context.Context, wrapperData *Data) {
+			timeout, ok := wrapperData.Get(key).(time.Duration)
+			if !ok {
+				next(ctx, wrapperData)
+				return
+			}
+
+			ctx, cancel := context.WithTimeout(ctx, timeout)
+			defer cancel()
+			next(ctx, wrapperData)
+		}
 	}
+}
 
-	m.wg.Add(1)
-	go func() {
-		defer m.wg.Done()
-		m.run(ctx, fn, opts...)
-	}()
+// WithMiddlewareSingleFlight will execute the fn once for the concurrent runs having the same identifier.
+// The other runs will wait until the execution is completed or their ctx is done, they are reported by IsCoalesced.
+// The result published by SetResult and the error of the execution are shared with them, see FuncManager.RunE,
+// and the error of their ctx is reported when they stop waiting. Runs without identifier are not affected.
+func WithMiddlewareSingleFlight() Middleware {
+	var (
+		mu    sync.Mutex
+		calls = make(map[string]*singleFlightCall)
+	)
+	return func(next HandleFunc) HandleFunc {
+		return func(ctx context.Context, wrapperData *Data) {
+			identifier := GetIdentifier(wrapperData)
+			if identifier == "" {
+				next(ctx, wrapperData)
+				return
+			}
+
+			mu.Lock()
+			if call, ok := calls[identifier]; ok {
+				mu.Unlock()
+				_ = wrapperData.Set(keyCoalesced, true)
+				select {
+				case <-call.done:
+					if call.result != nil {
+						_ = wrapperData.Set(keyResult, call.result)
+					}
+					if call.err != nil {
+						_ = wrapperData.Set(keyError, call.err)
+					}
+				case <-ctx.Done():
+					_ = wrapperData.Set(keyError, ctx.Err())
+				}
+				return
+			}
+			call := &singleFlightCall{done: make(chan struct{})}
+			calls[identifier] = call
+			mu.Unlock()
+
+			defer func() {
+				mu.Lock()
+				delete(calls, identifier)
+				mu.Unlock()
+				// the outcome is written before done is closed, so the waiters read it without the mu
+				call.result = wrapperData.Get(keyResult)
+				call.err, _ = wrapperData.Get(keyError).(error)
+				close(call.done)
+			}()
+
+			next(ctx, wrapperData)
+		}
+	}
 }
 
-func (m *funcManager) Wait() <-chan struct{} {
-	return m.shutdown
+// singleFlightCall is the execution shared by the runs of WithMiddlewareSingleFlight
+type singleFlightCall struct {
+	done   chan struct{}
+	result interface{}
+	err    error
 }
 
-func (m *funcManager) Shutdown(ctx context.Context) error {
-	if !atomic.CompareAndSwapInt32(&m.isShutdown, 0, 1) {
-		return ErrAlreadyShutdown
-	}
+// IsCoalesced will return true when the fn is not executed for the run, since it waits for the execution of another
+// run instead, see WithMiddlewareSingleFlight
+func IsCoalesced(wrapperData *Data) bool {
+	coalesced, _ := wrapperData.Get(keyCoalesced).(bool)
+	return coalesced
+}
 
-	defer func() {
-		close(m.shutdown)
-	}()
+// WithMiddlewareBulkhead will limit the concurrent runs per identifier, so a flood of one identifier can not starve
+// the others. The limit of the identifiers absent from the limits is the defaultLimit, a non-positive limit means
+// unlimited. The run exceeding its limit waits until a slot is released or its ctx is done, which skips the run.
+// Runs without identifier are not affected.
+func WithMiddlewareBulkhead(limits map[string]int, defaultLimit int) Middleware {
+	return bulkhead(limits, defaultLimit, false)
+}
 
-	m.mainCtxCancel()
+// WithMiddlewareBulkheadSkip is similar to WithMiddlewareBulkhead, but the run exceeding its limit is skipped
+// immediately instead of waiting
+func WithMiddlewareBulkheadSkip(limits map[string]int, defaultLimit int) Middleware {
+	return bulkhead(limits, defaultLimit, true)
+}
 
-	done := make(chan struct{})
-	go func() {
-		m.wg.Wait()
-		close(done)
-	}()
+func bulkhead(limits map[string]int, defaultLimit int, skip bool) Middleware {
+	return newBulkheadLimiter(limits, defaultLimit).middleware(skip)
+}
 
-	select {
-	case <-ctx.Done():
-		return ctx.Err()
-	case <-done:
-	}
+// bulkheadLimiter holds a semaphore per identifier while it is used by a run, the idle ones are removed,
+// so the identifiers of high cardinality do not pile up
+type bulkheadLimiter struct {
+	mu           sync.Mutex
+	entries      map[string]*bulkheadEntry
+	limits       map[string]int
+	defaultLimit int
+}
 
-	return nil
+type bulkheadEntry struct {
+	sem *semaphore
+	// number of the runs holding or waiting for the sem
+	users int
 }
 
-func (m *funcManager) run(ctx context.Context, fn HandleFunc, opts ...Option) {
-	if fn == nil {
-		return
+func newBulkheadLimiter(limits map[string]int, defaultLimit int) *bulkheadLimiter {
+	// the limits are read by the runs, so the later changes of the caller are not seen
+	limitsCopy := make(map[string]int, len(limits))
+	for identifier, limit := range limits {
+		limitsCopy[identifier] = limit
 	}
-	if ctx == nil {
-		ctx = context.Background()
+	return &bulkheadLimiter{
+		entries:      make(map[string]*bulkheadEntry),
+		limits:       limitsCopy,
+		defaultLimit: defaultLimit,
 	}
-	ctx, cancel := context.WithCancel(ctx)
-	defer cancel()
+}
 
-	wrapperData := &Data{}
+// enter returns the entry of the identifier used by the run, nil when the identifier is unlimited
+func (b *bulkheadLimiter) enter(identifier string) *bulkheadEntry {
+	b.mu.Lock()
+	defer b.mu.Unlock()
 
-	go func() {
-		select {
-		case <-ctx.Done():
-		case <-m.mainCtx.Done():
-			cancel()
+	entry, ok := b.entries[identifier]
+	if !ok {
+		limit, ok := b.limits[identifier]
+		if !ok {
+			limit = b.defaultLimit
 		}
-	}()
-
-	for _, opt := range opts {
-		if opt == nil {
-			continue
+		if limit <= 0 {
+			return nil
 		}
-		opt(wrapperData)
+		entry = &bulkheadEntry{sem: newSemaphore(int64(limit))}
+		b.entries[identifier] = entry
 	}
+	entry.users++
+	return entry
+}
 
-	for i := len(m.middlewares) - 1; i >= 0; i-- {
-		if m.middlewares[i] == nil {
-			continue
+// leave will remove the entry once it is not used by any run
+func (b *bulkheadLimiter) leave(identifier string, entry *bulkheadEntry) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	entry.users--
+	if entry.users == 0 {
+		delete(b.entries, identifier)
+	}
+}
+
+func (b *bulkheadLimiter) middleware(skip bool) Middleware {
+	return func(next HandleFunc) HandleFunc {
+		return func(ctx context.Context, wrapperData *Data) {
+			identifier := GetIdentifier(wrapperData)
+			if identifier == "" {
+				next(ctx, wrapperData)
+				return
+			}
+			entry := b.enter(identifier)
+			if entry == nil {
+				next(ctx, wrapperData)
+				return
+			}
+			defer b.leave(identifier, entry)
+
+			if skip {
+				if !entry.sem.TryAcquire(1) {
+					Skip(wrapperData, "bulkhead limit reached")
+					return
+				}
+			} else if err := entry.sem.Acquire(ctx, 1); err != nil {
+				Skip(wrapperData, "bulkhead: "+err.Error())
+				return
+			}
+			defer entry.sem.Release(1)
+
+			next(ctx, wrapperData)
 		}
-		fn = m.middlewares[i](fn)
 	}
+}
+
+// Config is the configuration of the FuncManager
+type Config struct {
+	// Middlewares are applied in order, the outermost first. The names set by NamedMiddleware are reported
+	// by FuncManager.Middlewares
+	Middlewares []Middleware
+	// MaxConcurrency limits the total weight of the running functions, see WithOptionWeight. Zero means unlimited
+	MaxConcurrency int
+	// OnRejected is called when a fn is submitted after the manager is shutdown, or when it is still queued for the
+	// workers once the shutdown is forced
+	OnRejected func(ctx context.Context, data *Data)
+	// Workers is the number of long-lived goroutines running the RunAsync jobs. Zero means a goroutine per job
+	Workers int
+	// Schedulers wrap the submit step of RunAsync, the first one is the outermost
+	Schedulers []SchedulerMiddleware
+	// OnShutdownDrain is called once by Shutdown after the running functions are cancelled, but before waiting for them.
+	// It is the place to release the resources blocking them, e.g. draining the request bodies
+	OnShutdownDrain func()
+	// MaxLifetime will shutdown the manager automatically once it is elapsed since the creation. Zero means unlimited
+	MaxLifetime time.Duration
+	// DefaultIdentifier is the identifier of the runs without WithOptionIdentifier
+	DefaultIdentifier string
+}
+
+// ConfigOption will modify the Config of NewFuncManagerWithConfig
+type ConfigOption func(config *Config)
+
+// maxLifetimeDrainTimeout bounds the wait of the automatic shutdown triggered by the MaxLifetime
+const maxLifetimeDrainTimeout = 30 * time.Second
+
+// WithDefaultIdentifier will set the Config.DefaultIdentifier
+func WithDefaultIdentifier(name string) ConfigOption {
+	return func(config *Config) {
+		config.DefaultIdentifier = name
+	}
+}
+
+// WithMaxLifetime will set the Config.MaxLifetime
+func WithMaxLifetime(d time.Duration) ConfigOption {
+	return func(config *Config) {
+		config.MaxLifetime = d
+	}
+}
+
+type funcManager struct {
+	wg            sync.WaitGroup
+	sem           *semaphore
+	semSize       int
+	isShutdown    int32
+	isStopped     int32
+	running       int32
+	peakRunning   int32
+	shutdown      chan struct{}
+	mainCtx       context.Context
+	mainCtxCancel context.CancelFunc
+	middlewaresMu sync.RWMutex
+	middlewares   []namedMiddleware
+	schedule      ScheduleFunc
+	schedulers    []SchedulerMiddleware
+	onRejected    func(ctx context.Context, data *Data)
+	onDrain       func()
+	defaultID     string
+	shutdownCause atomic.Value
+	jobs          chan asyncJob
+	stopWorkers   chan struct{}
+	goroutines    int32
+	idleMu        sync.Mutex
+	idle          chan struct{} // non nil while a goroutine waits for the wg
+	lifetimeMu    sync.Mutex
+	lifetimeTimer *time.Timer
+
+	activeMu sync.Mutex
+	active   map[*Data]struct{}
+
+	pauseMu sync.Mutex
+	resumed chan struct{} // non nil while paused
+
+	parent     *funcManager
+	childrenMu sync.Mutex
+	children   map[*funcManager]struct{}
+}
+
+type shutdownCause struct {
+	err error
+}
+
+func NewFuncManager(middlewares ...Middleware) FuncManager {
+	return NewFuncManagerWithConfig(Config{Middlewares: middlewares})
+}
+
+func NewFuncManagerWithConfig(config Config, opts ...ConfigOption) FuncManager {
+	for _, opt := range opts {
+		if opt == nil {
+			continue
+		}
+		opt(&config)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	m := &funcManager{
+		shutdown:      make(chan struct{}),
+		mainCtx:       ctx,
+		mainCtxCancel: cancel,
+		middlewares:   nameMiddlewares(config.Middlewares),
+		onRejected:    config.OnRejected,
+		onDrain:       config.OnShutdownDrain,
+		defaultID:     config.DefaultIdentifier,
+	}
+
+	if config.MaxConcurrency > 0 {
+		m.sem = newSemaphore(int64(config.MaxConcurrency))
+		m.semSize = config.MaxConcurrency
+	}
+
+	m.schedulers = config.Schedulers
+	m.initSchedule()
+
+	if config.Workers > 0 {
+		m.jobs = make(chan asyncJob, config.Workers)
+		m.stopWorkers = make(chan struct{})
+		for i := 0; i < config.Workers; i++ {
+			m.spawn(m.worker)
+		}
+	}
+
+	if config.MaxLifetime > 0 {
+		// the timer may fire before it is assigned
+		m.lifetimeMu.Lock()
+		defer m.lifetimeMu.Unlock()
+		m.lifetimeTimer = time.AfterFunc(config.MaxLifetime, func() {
+			ctx, cancel := context.WithTimeout(context.Background(), maxLifetimeDrainTimeout)
+			defer cancel()
+			_ = m.Shutdown(ctx)
+		})
+	}
+
+	return m
+}
+
+// initSchedule will wrap the submitAsync of m by its schedulers
+func (m *funcManager) initSchedule() {
+	m.schedule = m.submitAsync
+	for i := len(m.schedulers) - 1; i >= 0; i-- {
+		if m.schedulers[i] == nil {
+			continue
+		}
+		m.schedule = m.schedulers[i](m.schedule)
+	}
+}
+
+// NewFuncManagerWithWorkers will create a FuncManager running the RunAsync jobs on n long-lived goroutines
+func NewFuncManagerWithWorkers(n int, middlewares ...Middleware) FuncManager {
+	return NewFuncManagerWithConfig(Config{Middlewares: middlewares, Workers: n})
+}
+
+// spawn will run the fn in a goroutine counted by WaitAllGoroutines
+func (m *funcManager) spawn(fn func()) {
+	atomic.AddInt32(&m.goroutines, 1)
+	go func() {
+		defer atomic.AddInt32(&m.goroutines, -1)
+		fn()
+	}()
+}
+
+// WaitAllGoroutines will wait until all goroutines spawned by the m are done, it is intended for detecting leaks in
+// tests after Shutdown. It returns ErrGoroutineLeak when the timeout is reached, or an error for a foreign FuncManager
+func WaitAllGoroutines(m FuncManager, timeout time.Duration) error {
+	manager, ok := m.(*funcManager)
+	if !ok {
+		return errors.New("unsupported func manager")
+	}
+
+	deadline := time.Now().Add(timeout)
+	for atomic.LoadInt32(&manager.goroutines) > 0 {
+		if time.Now().After(deadline) {
+			return ErrGoroutineLeak
+		}
+		time.Sleep(time.Millisecond)
+	}
+	return nil
+}
+
+// asyncJob is a RunAsync job queued for the workers, abandon completes the job which is never run
+type asyncJob struct {
+	run     func()
+	abandon func(err error)
+}
+
+func (m *funcManager) worker() {
+	for {
+		select {
+		case job := <-m.jobs:
+			job.run()
+		case <-m.stopWorkers:
+			return
+		}
+	}
+}
+
+func (m *funcManager) Run(ctx context.Context, fn HandleFunc, opts ...Option) {
+	if atomic.LoadInt32(&m.isShutdown) == 1 {
+		m.reject(ctx, fn, opts...)
+		return
+	}
+
+	m.wg.Add(1)
+	defer m.wg.Done()
+	m.run(ctx, fn, opts...)
+}
+
+func (m *funcManager) RunAsync(ctx context.Context, fn HandleFunc, opts ...Option) {
+	m.schedule(ctx, fn, opts...)
+}
+
+// submitAsync is the default ScheduleFunc
+func (m *funcManager) submitAsync(ctx context.Context, fn HandleFunc, opts ...Option) {
+	if atomic.LoadInt32(&m.isShutdown) == 1 {
+		m.reject(ctx, fn, opts...)
+		return
+	}
+
+	m.wg.Add(1)
+	if m.jobs == nil {
+		m.spawn(func() {
+			defer m.wg.Done()
+			m.run(ctx, fn, opts...)
+		})
+		return
+	}
+
+	job := asyncJob{
+		run: func() {
+			defer m.wg.Done()
+			m.run(ctx, fn, opts...)
+		},
+		abandon: func(err error) {
+			defer m.wg.Done()
+			m.abandon(ctx, err, fn, opts...)
+		},
+	}
+	select {
+	case m.jobs <- job:
+	case <-m.mainCtx.Done():
+		// the manager is shutting down while waiting for an idle worker
+		m.wg.Done()
+		m.reject(ctx, fn, opts...)
+	}
+}
+
+func (m *funcManager) RunEvery(ctx context.Context, interval time.Duration, fn HandleFunc, opts ...Option) {
+	if atomic.LoadInt32(&m.isShutdown) == 1 {
+		m.reject(ctx, fn, opts...)
+		return
+	}
+	if fn == nil || interval <= 0 {
+		return
+	}
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	jitter, _ := newData(opts...).Get(keyJitter).(time.Duration)
+
+	m.wg.Add(1)
+	m.spawn(func() {
+		defer m.wg.Done()
+
+		for {
+			delay := interval
+			if jitter > 0 {
+				delay += time.Duration(randInt63n(int64(jitter) + 1))
+			}
+
+			timer := time.NewTimer(delay)
+			select {
+			case <-ctx.Done():
+				timer.Stop()
+				return
+			case <-m.mainCtx.Done():
+				timer.Stop()
+				return
+			case <-timer.C:
+			}
+
+			m.run(ctx, fn, opts...)
+		}
+	})
+}
+
+func (m *funcManager) RunE(ctx context.Context, fn ErrHandleFunc, opts ...Option) error {
+	if atomic.LoadInt32(&m.isShutdown) == 1 {
+		m.reject(ctx, handleFuncE(fn), opts...)
+		return ErrAlreadyShutdown
+	}
+
+	m.wg.Add(1)
+	defer m.wg.Done()
+	return m.runE(ctx, fn, opts...)
+}
+
+func (m *funcManager) RunAllE(ctx context.Context, fns ...ErrHandleFunc) error {
+	if atomic.LoadInt32(&m.isShutdown) == 1 {
+		for _, fn := range fns {
+			if fn == nil {
+				continue
+			}
+			m.reject(ctx, handleFuncE(fn))
+		}
+		return ErrAlreadyShutdown
+	}
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var (
+		wg       sync.WaitGroup
+		once     sync.Once
+		firstErr error
+	)
+	for _, fn := range fns {
+		if fn == nil {
+			continue
+		}
+
+		fn := fn
+		wg.Add(1)
+		m.wg.Add(1)
+		m.spawn(func() {
+			defer m.wg.Done()
+			defer wg.Done()
+
+			err := m.runE(ctx, fn)
+			if err != nil {
+				once.Do(func() {
+					firstErr = err
+					cancel()
+				})
+			}
+		})
+	}
+	wg.Wait()
+
+	return firstErr
+}
+
+func (m *funcManager) RunBatch(ctx context.Context, fns []HandleFunc, opts ...Option) {
+	var wg sync.WaitGroup
+	for _, fn := range fns {
+		if fn == nil {
+			continue
+		}
+		if atomic.LoadInt32(&m.isShutdown) == 1 {
+			m.reject(ctx, fn, opts...)
+			continue
+		}
+
+		fn := fn
+		wg.Add(1)
+		m.wg.Add(1)
+		m.spawn(func() {
+			defer m.wg.Done()
+			defer wg.Done()
+
+			m.run(ctx, fn, opts...)
+		})
+	}
+	wg.Wait()
+}
+
+func (m *funcManager) RunAsyncBatch(ctx context.Context, fns []HandleFunc, opts ...Option) {
+	for _, fn := range fns {
+		if fn == nil {
+			continue
+		}
+		m.schedule(ctx, fn, opts...)
+	}
+}
+
+func (m *funcManager) Child(middlewares ...Middleware) FuncManager {
+	ctx, cancel := context.WithCancel(m.mainCtx)
+
+	parentMiddlewares := m.getMiddlewares()
+	child := &funcManager{
+		shutdown:      make(chan struct{}),
+		mainCtx:       ctx,
+		mainCtxCancel: cancel,
+		middlewares:   append(parentMiddlewares[:len(parentMiddlewares):len(parentMiddlewares)], nameMiddlewares(middlewares)...),
+		// the runs of the child count against the limit of the parent and are run by its workers
+		sem:        m.sem,
+		semSize:    m.semSize,
+		jobs:       m.jobs,
+		schedulers: m.schedulers,
+		onRejected: m.onRejected,
+		defaultID:  m.defaultID,
+		parent:     m,
+	}
+	child.initSchedule()
+
+	m.childrenMu.Lock()
+	if atomic.LoadInt32(&m.isShutdown) == 1 {
+		m.childrenMu.Unlock()
+		_ = child.Shutdown(context.Background())
+		return child
+	}
+	if m.children == nil {
+		m.children = make(map[*funcManager]struct{})
+	}
+	m.children[child] = struct{}{}
+	m.childrenMu.Unlock()
+
+	return child
+}
+
+func (m *funcManager) Pause() {
+	m.pauseMu.Lock()
+	defer m.pauseMu.Unlock()
+	if m.resumed == nil {
+		m.resumed = make(chan struct{})
+	}
+}
+
+func (m *funcManager) Resume() {
+	m.pauseMu.Lock()
+	defer m.pauseMu.Unlock()
+	if m.resumed != nil {
+		close(m.resumed)
+		m.resumed = nil
+	}
+}
+
+// waitResumed will block while the manager is paused
+func (m *funcManager) waitResumed(ctx context.Context) error {
+	m.pauseMu.Lock()
+	resumed := m.resumed
+	m.pauseMu.Unlock()
+
+	if resumed == nil {
+		return nil
+	}
+	select {
+	case <-resumed:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-m.mainCtx.Done():
+		return m.mainCtx.Err()
+	}
+}
+
+func (m *funcManager) Middlewares() []string {
+	middlewares := m.getMiddlewares()
+	names := make([]string, 0, len(middlewares))
+	for _, mw := range middlewares {
+		if mw.middleware == nil {
+			continue
+		}
+		names = append(names, mw.name)
+	}
+	return names
+}
+
+func (m *funcManager) SetMiddlewares(middlewares ...Middleware) {
+	named := nameMiddlewares(middlewares)
+
+	m.middlewaresMu.Lock()
+	defer m.middlewaresMu.Unlock()
+	m.middlewares = named
+}
+
+// getMiddlewares will return the current middlewares, the returned slice is never modified in place
+func (m *funcManager) getMiddlewares() []namedMiddleware {
+	m.middlewaresMu.RLock()
+	defer m.middlewaresMu.RUnlock()
+	return m.middlewares
+}
+
+func (m *funcManager) Wait() <-chan struct{} {
+	return m.shutdown
+}
+
+func (m *funcManager) WaitCause() error {
+	<-m.shutdown
+	cause, _ := m.shutdownCause.Load().(shutdownCause)
+	return cause.err
+}
+
+func (m *funcManager) Stats() ManagerStats {
+	stats := ManagerStats{
+		Running:         m.Running(),
+		PeakConcurrency: m.PeakConcurrency(),
+	}
+	if cause, ok := m.shutdownCause.Load().(shutdownCause); ok {
+		stats.ShutdownMode = ShutdownModeGraceful
+		if cause.err != nil {
+			stats.ShutdownMode = ShutdownModeForced
+		}
+	}
+	return stats
+}
+
+func (m *funcManager) Running() int {
+	return int(atomic.LoadInt32(&m.running))
+}
+
+func (m *funcManager) PeakConcurrency() int {
+	return int(atomic.LoadInt32(&m.peakRunning))
+}
+
+// trackRunning will count the executing fn, the returned func must be called once the fn is done
+func (m *funcManager) trackRunning() func() {
+	running := atomic.AddInt32(&m.running, 1)
+	for {
+		peak := atomic.LoadInt32(&m.peakRunning)
+		if running <= peak || atomic.CompareAndSwapInt32(&m.peakRunning, peak, running) {
+			break
+		}
+	}
+
+	return func() {
+		atomic.AddInt32(&m.running, -1)
+	}
+}
+
+func (m *funcManager) StopAccepting() {
+	atomic.StoreInt32(&m.isShutdown, 1)
+
+	for _, child := range m.childManagers() {
+		child.StopAccepting()
+	}
+}
+
+func (m *funcManager) Drain(ctx context.Context) error {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	for _, child := range m.childManagers() {
+		if err := child.Drain(ctx); err != nil {
+			return err
+		}
+	}
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-m.waitIdle():
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	if atomic.LoadInt32(&m.isShutdown) == 0 {
+		// still accepting, the drained state may not last
+		return nil
+	}
+	// nothing is running, so the shutdown completes without cancelling any function
+	if err := m.Shutdown(ctx); err != nil && !errors.Is(err, ErrAlreadyShutdown) {
+		return err
+	}
+	return nil
+}
+
+// waitIdle returns a channel closed once no function is running. The goroutine waiting for the wg is shared by all
+// the callers, so a caller giving up on its ctx does not leave another goroutine behind
+func (m *funcManager) waitIdle() <-chan struct{} {
+	m.idleMu.Lock()
+	defer m.idleMu.Unlock()
+
+	if m.idle != nil {
+		return m.idle
+	}
+	idle := make(chan struct{})
+	m.idle = idle
+	m.spawn(func() {
+		m.wg.Wait()
+
+		m.idleMu.Lock()
+		m.idle = nil
+		m.idleMu.Unlock()
+		close(idle)
+	})
+	return idle
+}
+
+func (m *funcManager) childManagers() []*funcManager {
+	m.childrenMu.Lock()
+	defer m.childrenMu.Unlock()
+
+	children := make([]*funcManager, 0, len(m.children))
+	for child := range m.children {
+		children = append(children, child)
+	}
+	return children
+}
+
+func (m *funcManager) Shutdown(ctx context.Context) error {
+	// the intake may already be stopped by StopAccepting, isStopped guards the shutdown itself
+	if !atomic.CompareAndSwapInt32(&m.isStopped, 0, 1) {
+		return ErrAlreadyShutdown
+	}
+	atomic.StoreInt32(&m.isShutdown, 1)
+
+	defer func() {
+		close(m.shutdown)
+	}()
+
+	m.mainCtxCancel()
+
+	m.lifetimeMu.Lock()
+	if m.lifetimeTimer != nil {
+		m.lifetimeTimer.Stop()
+	}
+	m.lifetimeMu.Unlock()
+
+	if m.onDrain != nil {
+		m.onDrain()
+	}
+
+	if m.parent != nil {
+		m.parent.childrenMu.Lock()
+		delete(m.parent.children, m)
+		m.parent.childrenMu.Unlock()
+	}
+
+	children := m.childManagers()
+
+	done := make(chan struct{})
+	m.spawn(func() {
+		childrenWg := sync.WaitGroup{}
+		for _, child := range children {
+			childrenWg.Add(1)
+			child := child
+			m.spawn(func() {
+				defer childrenWg.Done()
+				_ = child.Shutdown(ctx)
+			})
+		}
+		childrenWg.Wait()
+		m.wg.Wait()
+		close(done)
+	})
+
+	var err error
+	select {
+	case <-ctx.Done():
+		err = ctx.Err()
+	case <-done:
+	}
+
+	if m.stopWorkers != nil {
+		close(m.stopWorkers)
+		if err != nil {
+			m.abandonJobs(err)
+		}
+	}
+
+	m.shutdownCause.Store(shutdownCause{err: err})
+	return err
+}
+
+func (m *funcManager) ShutdownReport(ctx context.Context) ([]string, error) {
+	err := m.Shutdown(ctx)
+	if err == nil || errors.Is(err, ErrAlreadyShutdown) {
+		return nil, err
+	}
+
+	m.activeMu.Lock()
+	defer m.activeMu.Unlock()
+
+	var identifiers []string
+	for wrapperData := range m.active {
+		if identifier := GetIdentifier(wrapperData); identifier != "" {
+			identifiers = append(identifiers, identifier)
+		}
+	}
+	sort.Strings(identifiers)
+	return identifiers, err
+}
+
+func (m *funcManager) trackActive(wrapperData *Data) func() {
+	m.activeMu.Lock()
+	defer m.activeMu.Unlock()
+
+	if m.active == nil {
+		m.active = make(map[*Data]struct{})
+	}
+	m.active[wrapperData] = struct{}{}
+
+	return func() {
+		m.activeMu.Lock()
+		defer m.activeMu.Unlock()
+		delete(m.active, wrapperData)
+	}
+}
+
+func (m *funcManager) reject(ctx context.Context, fn HandleFunc, opts ...Option) {
+	if fn == nil || m.onRejected == nil {
+		return
+	}
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	m.onRejected(ctx, m.newData(opts...))
+}
+
+// abandonJobs will complete the jobs still queued for the stopped workers with the err
+func (m *funcManager) abandonJobs(err error) {
+	for {
+		select {
+		case job := <-m.jobs:
+			job.abandon(err)
+		default:
+			return
+		}
+	}
+}
+
+// abandon will complete the fn which is never run, the err is stored in its Data, a nil is sent to
+// its result channel and the OnRejected is called
+func (m *funcManager) abandon(ctx context.Context, err error, fn HandleFunc, opts ...Option) {
+	if fn == nil {
+		return
+	}
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	wrapperData := m.newData(opts...)
+	_ = wrapperData.Set(keyError, err)
+	if ch, ok := wrapperData.Get(keyResultChannel).(chan<- interface{}); ok {
+		select {
+		case ch <- nil:
+		default:
+		}
+	}
+	if m.onRejected != nil {
+		m.onRejected(ctx, wrapperData)
+	}
+}
+
+// newData will create the Data of a run, the default identifier is applied before the opts, so they can override it
+func (m *funcManager) newData(opts ...Option) *Data {
+	if m.defaultID == "" {
+		return newData(opts...)
+	}
+	return newData(append([]Option{WithOptionIdentifier(m.defaultID)}, opts...)...)
+}
+
+func newData(opts ...Option) *Data {
+	wrapperData := &Data{}
+	for _, opt := range opts {
+		if opt == nil {
+			continue
+		}
+		opt(wrapperData)
+	}
+	return wrapperData
+}
+
+// runE will run the fn and return the error stored in its Data, so the error set by the middlewares is also reported
+func (m *funcManager) runE(ctx context.Context, fn ErrHandleFunc, opts ...Option) error {
+	var wrapperData *Data
+	opts = append(opts[:len(opts):len(opts)], func(data *Data) {
+		wrapperData = data
+	})
+
+	m.run(ctx, handleFuncE(fn), opts...)
+
+	if wrapperData == nil {
+		return nil
+	}
+	err, _ := wrapperData.Get(keyError).(error)
+	return err
+}
+
+// handleFuncE will adapt the fn to HandleFunc, the error is stored in the Data
+func handleFuncE(fn ErrHandleFunc) HandleFunc {
+	if fn == nil {
+		return nil
+	}
+	return func(ctx context.Context, wrapperData *Data) {
+		if err := fn(ctx, wrapperData); err != nil {
+			_ = wrapperData.Set(keyError, err)
+		}
+	}
+}
+
+func (m *funcManager) run(ctx context.Context, fn HandleFunc, opts ...Option) {
+	if fn == nil {
+		return
+	}
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	middlewares := m.getMiddlewares()
+	wrapperData := m.newData(opts...)
+
+	completed := false
+	if ch, ok := wrapperData.Get(keyResultChannel).(chan<- interface{}); ok {
+		defer func() {
+			var result interface{}
+			if completed {
+				result = wrapperData.Get(keyResult)
+			}
+			select {
+			case ch <- result:
+				return
+			default:
+			}
+			select {
+			case ch <- result:
+			case <-ctx.Done():
+			case <-m.mainCtx.Done():
+			}
+		}()
+	}
+
+	if values, ok := wrapperData.Get(keyContextValues).([][2]interface{}); ok {
+		for _, pair := range values {
+			ctx = context.WithValue(ctx, pair[0], pair[1])
+		}
+	}
+	ctx = withTraceID(ctx, wrapperData)
+	ctx = context.WithValue(ctx, keyData, wrapperData)
+
+	if noShutdownWatch, _ := wrapperData.Get(keyNoShutdownWatch).(bool); noShutdownWatch {
+		if m.mainCtx.Err() != nil {
+			cancel()
+		}
+	} else {
+		m.spawn(func() {
+			select {
+			case <-ctx.Done():
+			case <-m.mainCtx.Done():
+				cancel()
+			}
+		})
+	}
+
+	if m.waitResumed(ctx) != nil {
+		return
+	}
+
+	if m.sem != nil {
+		weight, ok := wrapperData.Get(keyWeight).(int)
+		if !ok {
+			weight = 1
+		}
+		if weight > m.semSize {
+			weight = m.semSize
+		}
+		if m.sem.Acquire(ctx, int64(weight)) != nil {
+			return
+		}
+		defer m.sem.Release(int64(weight))
+	}
+
+	for i := len(middlewares) - 1; i >= 0; i-- {
+		if middlewares[i].middleware == nil {
+			continue
+		}
+		fn = middlewares[i].middleware(fn)
+	}
+
+	if cb, ok := wrapperData.Get(keyOnComplete).(func(data *Data, panicked bool)); ok {
+		defer func() {
+			cb(wrapperData, !completed)
+		}()
+	}
+
+	// registered once executing, the runs held by Pause or waiting for the concurrency are not reported
+	defer m.trackActive(wrapperData)()
+	defer m.trackRunning()()
 
 	fn(ctx, wrapperData)
+	completed = true
 }
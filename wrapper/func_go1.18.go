@@ -0,0 +1,38 @@
+//go:build go1.18
+// +build go1.18
+
+package wrapper
+
+import "context"
+
+// WithOptionKey will tag the run with a typed identifier. Use GetKey with the same type to retrieve it.
+func WithOptionKey[T comparable](key T) Option {
+	return func(data *Data) {
+		_ = data.Set(keyTypedKey, key)
+	}
+}
+
+// GetKey will return the typed identifier set by WithOptionKey. It returns false if the key is absent or has a different type.
+func GetKey[T comparable](data *Data) (T, bool) {
+	val, ok := data.Get(keyTypedKey).(T)
+	return val, ok
+}
+
+// RunResult will run the fn synchronously through the middlewares of m and return its typed result along with its error,
+// see FuncManager.RunE. The zero value is returned when the fn is not completed, e.g. it panics
+func RunResult[T any](ctx context.Context, m FuncManager, fn func(ctx context.Context, data *Data) (T, error), opts ...Option) (T, error) {
+	var (
+		result T
+		errFn  ErrHandleFunc
+	)
+	if fn != nil {
+		errFn = func(ctx context.Context, data *Data) error {
+			var err error
+			result, err = fn(ctx, data)
+			return err
+		}
+	}
+
+	err := m.RunE(ctx, errFn, opts...)
+	return result, err
+}
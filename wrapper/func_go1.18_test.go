@@ -0,0 +1,96 @@
+//go:build go1.18
+// +build go1.18
+
+package wrapper
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+type testJobKind int
+
+const (
+	testJobKindUnknown testJobKind = iota
+	testJobKindImport
+)
+
+type testJobID string
+
+func TestOptionKey(t *testing.T) {
+	m := NewFuncManager()
+
+	m.Run(context.Background(), func(ctx context.Context, wrapperData *Data) {
+		kind, ok := GetKey[testJobKind](wrapperData)
+		if !ok || kind != testJobKindImport {
+			t.Errorf("invalid key, got: %v %v", kind, ok)
+		}
+
+		// same underlying type but different named type
+		intKey, ok := GetKey[int](wrapperData)
+		if ok || intKey != 0 {
+			t.Errorf("key should not be retrievable as int, got: %v %v", intKey, ok)
+		}
+	}, WithOptionKey(testJobKindImport))
+
+	m.Run(context.Background(), func(ctx context.Context, wrapperData *Data) {
+		id, ok := GetKey[testJobID](wrapperData)
+		if !ok || id != "job-1" {
+			t.Errorf("invalid key, got: %v %v", id, ok)
+		}
+
+		strKey, ok := GetKey[string](wrapperData)
+		if ok || strKey != "" {
+			t.Errorf("key should not be retrievable as string, got: %v %v", strKey, ok)
+		}
+		if GetIdentifier(wrapperData) != "" {
+			t.Errorf("typed key should not be used as identifier")
+		}
+	}, WithOptionKey(testJobID("job-1")))
+
+	m.Run(context.Background(), func(ctx context.Context, wrapperData *Data) {
+		kind, ok := GetKey[testJobKind](wrapperData)
+		if ok || kind != testJobKindUnknown {
+			t.Errorf("key should be absent, got: %v %v", kind, ok)
+		}
+	})
+}
+
+func TestRunResult(t *testing.T) {
+	m := NewFuncManager(WithMiddlewarePanicToError(nil))
+
+	val, err := RunResult(context.Background(), m, func(ctx context.Context, data *Data) (int, error) {
+		return 42, nil
+	})
+	if err != nil || val != 42 {
+		t.Errorf("unexpected result: %v %v", val, err)
+	}
+
+	errTest := errors.New("test")
+	str, err := RunResult(context.Background(), m, func(ctx context.Context, data *Data) (string, error) {
+		return "partial", errTest
+	})
+	if !errors.Is(err, errTest) || str != "partial" {
+		t.Errorf("unexpected result: %v %v", str, err)
+	}
+
+	val, err = RunResult(context.Background(), m, func(ctx context.Context, data *Data) (int, error) {
+		panic("boom")
+	})
+	if err == nil || err.Error() != "panic: boom" || val != 0 {
+		t.Errorf("unexpected result: %v %v", val, err)
+	}
+
+	err = m.Shutdown(context.Background())
+	if err != nil {
+		t.Errorf("unexpected shutdown error: %v", err)
+	}
+
+	_, err = RunResult(context.Background(), m, func(ctx context.Context, data *Data) (int, error) {
+		return 42, nil
+	})
+	if !errors.Is(err, ErrAlreadyShutdown) {
+		t.Errorf("unexpected error after shutdown: %v", err)
+	}
+}
@@ -3,7 +3,9 @@ package wrapper
 import (
 	"context"
 	"errors"
+	"fmt"
 	"log"
+	"math/rand"
 	"sync"
 	"sync/atomic"
 	"testing"
@@ -205,3 +207,1840 @@ func TestData(t *testing.T) {
 		t.Errorf("invalid checker, checker is not 0. checker: %d", checker)
 	}
 }
+
+func TestOptionNoShutdownWatch(t *testing.T) {
+	m := NewFuncManager()
+	started := make(chan struct{})
+	finish := make(chan struct{})
+	errCh := make(chan error, 1)
+
+	m.RunAsync(context.Background(), func(ctx context.Context, wrapperData *Data) {
+		close(started)
+		<-finish
+		errCh <- ctx.Err()
+	}, WithOptionNoShutdownWatch())
+
+	<-started
+	ctxShutdown, cancelCtxShutdown := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancelCtxShutdown()
+	err := m.Shutdown(ctxShutdown)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("shutdown should wait for the running function, got: %v", err)
+	}
+	close(finish)
+
+	if err := <-errCh; err != nil {
+		t.Errorf("context should not be cancelled by shutdown, got: %v", err)
+	}
+}
+
+func BenchmarkRun(b *testing.B) {
+	m := NewFuncManager()
+	fn := func(ctx context.Context, wrapperData *Data) {}
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		m.Run(context.Background(), fn)
+	}
+}
+
+func BenchmarkRunNoShutdownWatch(b *testing.B) {
+	m := NewFuncManager()
+	fn := func(ctx context.Context, wrapperData *Data) {}
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		m.Run(context.Background(), fn, WithOptionNoShutdownWatch())
+	}
+}
+
+func TestWaitCause(t *testing.T) {
+	m := NewFuncManager()
+	m.RunAsync(context.Background(), func(ctx context.Context, wrapperData *Data) {
+		<-ctx.Done()
+	})
+
+	err := m.Shutdown(context.Background())
+	if err != nil {
+		t.Errorf("unexpected shutdown error: %v", err)
+	}
+	if err := m.WaitCause(); err != nil {
+		t.Errorf("clean shutdown should have nil cause, got: %v", err)
+	}
+
+	m = NewFuncManager()
+	finish := make(chan struct{})
+	defer close(finish)
+	m.RunAsync(context.Background(), func(ctx context.Context, wrapperData *Data) {
+		<-finish
+	})
+
+	ctxShutdown, cancelCtxShutdown := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancelCtxShutdown()
+	go func() {
+		_ = m.Shutdown(ctxShutdown)
+	}()
+
+	if err := m.WaitCause(); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("forced shutdown should have deadline exceeded cause, got: %v", err)
+	}
+}
+
+func TestMiddlewareSingleFlight(t *testing.T) {
+	const total = 10
+	arrived := int32(0)
+	executed := int32(0)
+	release := make(chan struct{})
+	wg := sync.WaitGroup{}
+
+	m := NewFuncManager(
+		func(next HandleFunc) HandleFunc {
+			return func(ctx context.Context, wrapperData *Data) {
+				defer wg.Done()
+				atomic.AddInt32(&arrived, 1)
+				next(ctx, wrapperData)
+			}
+		},
+		WithMiddlewareSingleFlight(),
+	)
+
+	wg.Add(total)
+	for i := 0; i < total; i++ {
+		m.RunAsync(context.Background(), func(ctx context.Context, wrapperData *Data) {
+			atomic.AddInt32(&executed, 1)
+			<-release
+		}, WithOptionIdentifier("load-cache"))
+	}
+
+	for atomic.LoadInt32(&arrived) < total {
+		time.Sleep(time.Millisecond)
+	}
+	time.Sleep(50 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	if executed != 1 {
+		t.Errorf("fn should be executed once, executed: %d", executed)
+	}
+}
+
+func TestMiddlewareSingleFlightOutcome(t *testing.T) {
+	const total = 5
+	m := NewFuncManager(WithMiddlewareSingleFlight())
+	defer func() {
+		_ = m.Shutdown(context.Background())
+	}()
+
+	errLoad := errors.New("load failed")
+	started := make(chan struct{})
+	release := make(chan struct{})
+	results := make(chan interface{}, total)
+	var (
+		wg        sync.WaitGroup
+		mu        sync.Mutex
+		errs      []error
+		coalesced int
+	)
+	for i := 0; i < total; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			var wrapperData *Data
+			err := m.RunE(context.Background(), func(ctx context.Context, data *Data) error {
+				close(started)
+				<-release
+				SetResult(data, "loaded")
+				return errLoad
+			}, WithOptionIdentifier("load"), WithOptionResultChannel(results), func(data *Data) {
+				wrapperData = data
+			})
+
+			mu.Lock()
+			defer mu.Unlock()
+			errs = append(errs, err)
+			if IsCoalesced(wrapperData) {
+				coalesced++
+			}
+		}()
+		if i == 0 {
+			<-started
+		}
+	}
+
+	time.Sleep(50 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	// the waiters get the outcome of the execution
+	if coalesced != total-1 {
+		t.Errorf("unexpected coalesced runs: %d", coalesced)
+	}
+	for _, err := range errs {
+		if !errors.Is(err, errLoad) {
+			t.Errorf("unexpected error: %v", err)
+		}
+	}
+	for i := 0; i < total; i++ {
+		if result := <-results; result != "loaded" {
+			t.Errorf("unexpected result: %v", result)
+		}
+	}
+
+	// the waiter giving up reports its ctx error
+	release = make(chan struct{})
+	started = make(chan struct{})
+	go m.Run(context.Background(), func(ctx context.Context, data *Data) {
+		close(started)
+		<-release
+	}, WithOptionIdentifier("slow"))
+	<-started
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	err := m.RunE(ctx, func(ctx context.Context, data *Data) error {
+		t.Error("fn should not be executed by the waiter")
+		return nil
+	}, WithOptionIdentifier("slow"))
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("unexpected error of the waiter: %v", err)
+	}
+	close(release)
+}
+
+func TestMiddlewareRecoverPanicCtx(t *testing.T) {
+	var (
+		ctxErr     error
+		recoverVal interface{}
+	)
+	m := NewFuncManager(
+		WithMiddlewareRecoverPanicCtx(func(ctx context.Context, val interface{}, wrapperData *Data) {
+			ctxErr = ctx.Err()
+			recoverVal = val
+		}),
+	)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	m.Run(ctx, func(ctx context.Context, wrapperData *Data) {
+		<-ctx.Done()
+		panic("deadline")
+	})
+
+	if !errors.Is(ctxErr, context.DeadlineExceeded) {
+		t.Errorf("ctx should be expired, got: %v", ctxErr)
+	}
+	if recoverVal != "deadline" {
+		t.Errorf("invalid recover value, got: %v", recoverVal)
+	}
+
+	ctxErr = nil
+	recoverVal = nil
+	m.Run(context.Background(), func(ctx context.Context, wrapperData *Data) {
+		panic("no deadline")
+	})
+
+	if ctxErr != nil {
+		t.Errorf("ctx should not be expired, got: %v", ctxErr)
+	}
+	if recoverVal != "no deadline" {
+		t.Errorf("invalid recover value, got: %v", recoverVal)
+	}
+}
+
+func TestOptionContextValues(t *testing.T) {
+	type ctxKey string
+	checker := int32(4)
+
+	m := NewFuncManager(func(next HandleFunc) HandleFunc {
+		return func(ctx context.Context, wrapperData *Data) {
+			if ctx.Value(ctxKey("request-id")) == "req-1" {
+				atomic.AddInt32(&checker, -1)
+			}
+			next(ctx, wrapperData)
+		}
+	})
+
+	m.Run(context.Background(), func(ctx context.Context, wrapperData *Data) {
+		if ctx.Value(ctxKey("request-id")) == "req-1" {
+			atomic.AddInt32(&checker, -1)
+		}
+		if ctx.Value(ctxKey("user")) == 123 {
+			atomic.AddInt32(&checker, -1)
+		}
+		if ctx.Value("tenant") == "acme" {
+			atomic.AddInt32(&checker, -1)
+		}
+	},
+		WithOptionContextValues(
+			[2]interface{}{ctxKey("request-id"), "req-1"},
+			[2]interface{}{ctxKey("user"), 123},
+			[2]interface{}{nil, "skipped"},
+			[2]interface{}{[]string{"non-comparable"}, "skipped"},
+		),
+		WithOptionContextValues([2]interface{}{"tenant", "acme"}),
+	)
+
+	if checker != 0 {
+		t.Errorf("invalid checker, checker is not 0. checker: %d", checker)
+	}
+}
+
+func TestSkip(t *testing.T) {
+	skipped := make(map[string]string)
+	executed := make(map[string]bool)
+
+	m := NewFuncManager(
+		// metrics
+		func(next HandleFunc) HandleFunc {
+			return func(ctx context.Context, wrapperData *Data) {
+				next(ctx, wrapperData)
+				if ok, reason := WasSkipped(wrapperData); ok {
+					skipped[GetIdentifier(wrapperData)] = reason
+				}
+			}
+		},
+		// auth
+		func(next HandleFunc) HandleFunc {
+			return func(ctx context.Context, wrapperData *Data) {
+				if GetIdentifier(wrapperData) != "admin" {
+					Skip(wrapperData, "unauthorized")
+					return
+				}
+				next(ctx, wrapperData)
+			}
+		},
+	)
+
+	fn := func(ctx context.Context, wrapperData *Data) {
+		executed[GetIdentifier(wrapperData)] = true
+	}
+	m.Run(context.Background(), fn, WithOptionIdentifier("admin"))
+	m.Run(context.Background(), fn, WithOptionIdentifier("guest"))
+
+	if !executed["admin"] || executed["guest"] {
+		t.Errorf("invalid executed runs: %v", executed)
+	}
+	if len(skipped) != 1 || skipped["guest"] != "unauthorized" {
+		t.Errorf("invalid skipped runs: %v", skipped)
+	}
+}
+
+func TestOptionWeight(t *testing.T) {
+	const maxConcurrency = 4
+	current := int32(0)
+	peak := int32(0)
+	violated := int32(0)
+	wg := sync.WaitGroup{}
+
+	m := NewFuncManagerWithConfig(Config{MaxConcurrency: maxConcurrency})
+
+	job := func(weight int32) HandleFunc {
+		return func(ctx context.Context, wrapperData *Data) {
+			defer wg.Done()
+			total := atomic.AddInt32(&current, weight)
+			defer atomic.AddInt32(&current, -weight)
+			if total > maxConcurrency {
+				atomic.StoreInt32(&violated, 1)
+			}
+			for {
+				p := atomic.LoadInt32(&peak)
+				if total <= p || atomic.CompareAndSwapInt32(&peak, p, total) {
+					break
+				}
+			}
+			time.Sleep(20 * time.Millisecond)
+		}
+	}
+
+	wg.Add(11)
+	m.RunAsync(context.Background(), job(3), WithOptionWeight(3))
+	for i := 0; i < 8; i++ {
+		m.RunAsync(context.Background(), job(1), WithOptionWeight(1))
+	}
+	// invalid weight defaults to 1
+	m.RunAsync(context.Background(), job(1), WithOptionWeight(0))
+	// weight greater than max concurrency is capped
+	m.RunAsync(context.Background(), job(maxConcurrency), WithOptionWeight(10))
+	wg.Wait()
+
+	if violated != 0 {
+		t.Errorf("total weight exceeds the max concurrency")
+	}
+	if peak != maxConcurrency {
+		t.Errorf("peak weight should reach the max concurrency, peak: %d", peak)
+	}
+
+	err := m.Shutdown(context.Background())
+	if err != nil {
+		t.Errorf("unexpected shutdown error: %v", err)
+	}
+}
+
+func TestOptionWeightShutdown(t *testing.T) {
+	executed := int32(0)
+	release := make(chan struct{})
+	started := make(chan struct{})
+
+	m := NewFuncManagerWithConfig(Config{MaxConcurrency: 1})
+	m.RunAsync(context.Background(), func(ctx context.Context, wrapperData *Data) {
+		close(started)
+		<-release
+	})
+	<-started
+
+	// waiting for the slot, will not be executed after shutdown
+	m.RunAsync(context.Background(), func(ctx context.Context, wrapperData *Data) {
+		atomic.AddInt32(&executed, 1)
+	})
+
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		close(release)
+	}()
+
+	err := m.Shutdown(context.Background())
+	if err != nil {
+		t.Errorf("unexpected shutdown error: %v", err)
+	}
+	if executed != 0 {
+		t.Errorf("waiting fn should not be executed after shutdown")
+	}
+}
+
+func TestOnRejected(t *testing.T) {
+	rejected := make([]string, 0)
+	m := NewFuncManagerWithConfig(Config{
+		OnRejected: func(ctx context.Context, data *Data) {
+			rejected = append(rejected, GetIdentifier(data))
+		},
+	})
+
+	m.Run(context.Background(), func(ctx context.Context, wrapperData *Data) {}, WithOptionIdentifier("normal"))
+
+	err := m.Shutdown(context.Background())
+	if err != nil {
+		t.Errorf("unexpected shutdown error: %v", err)
+	}
+
+	m.Run(context.Background(), func(ctx context.Context, wrapperData *Data) {}, WithOptionIdentifier("sync"))
+	m.RunAsync(nil, func(ctx context.Context, wrapperData *Data) {}, WithOptionIdentifier("async"))
+	m.Run(context.Background(), nil, WithOptionIdentifier("nil"))
+
+	if len(rejected) != 2 || rejected[0] != "sync" || rejected[1] != "async" {
+		t.Errorf("invalid rejected runs: %v", rejected)
+	}
+}
+
+func TestOnRejectedRunE(t *testing.T) {
+	rejected := 0
+	m := NewFuncManagerWithConfig(Config{
+		OnRejected: func(ctx context.Context, data *Data) {
+			rejected++
+		},
+	})
+
+	err := m.Shutdown(context.Background())
+	if err != nil {
+		t.Errorf("unexpected shutdown error: %v", err)
+	}
+
+	fn := func(ctx context.Context, wrapperData *Data) error { return nil }
+	err = m.RunE(context.Background(), fn)
+	if err != ErrAlreadyShutdown {
+		t.Errorf("invalid RunE error, got: %v", err)
+	}
+	if rejected != 1 {
+		t.Errorf("RunE should be rejected, got: %d", rejected)
+	}
+
+	err = m.RunAllE(context.Background(), fn, nil, fn)
+	if err != ErrAlreadyShutdown {
+		t.Errorf("invalid RunAllE error, got: %v", err)
+	}
+	if rejected != 3 {
+		t.Errorf("each fn of RunAllE should be rejected, got: %d", rejected)
+	}
+}
+
+func TestDataFromContext(t *testing.T) {
+	var (
+		handlerData *Data
+		nestedData  *Data
+	)
+	nested := func(ctx context.Context) {
+		nestedData = DataFromContext(ctx)
+	}
+
+	m := NewFuncManager()
+	m.Run(context.Background(), func(ctx context.Context, wrapperData *Data) {
+		handlerData = wrapperData
+		nested(ctx)
+	}, WithOptionIdentifier("nested"))
+
+	if handlerData == nil || handlerData != nestedData {
+		t.Errorf("invalid data from context")
+	}
+	if GetIdentifier(nestedData) != "nested" {
+		t.Errorf("invalid identifier, got: %s", GetIdentifier(nestedData))
+	}
+	if DataFromContext(context.Background()) != nil {
+		t.Errorf("data should be nil for an unrelated ctx")
+	}
+}
+
+func TestRunEvery(t *testing.T) {
+	executed := int32(0)
+	m := NewFuncManager()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	m.RunEvery(ctx, 10*time.Millisecond, func(ctx context.Context, wrapperData *Data) {
+		if atomic.AddInt32(&executed, 1) == 3 {
+			cancel()
+		}
+	})
+	<-ctx.Done()
+
+	m.RunEvery(context.Background(), 10*time.Millisecond, func(ctx context.Context, wrapperData *Data) {})
+	m.RunEvery(context.Background(), 0, func(ctx context.Context, wrapperData *Data) {
+		t.Errorf("invalid interval should not be executed")
+	})
+
+	// the loop is stopped by the shutdown
+	err := m.Shutdown(context.Background())
+	if err != nil {
+		t.Errorf("unexpected shutdown error: %v", err)
+	}
+	if executed != 3 {
+		t.Errorf("fn should be executed 3 times, executed: %d", executed)
+	}
+}
+
+func TestOptionJitter(t *testing.T) {
+	const (
+		interval  = 20 * time.Millisecond
+		maxJitter = 30 * time.Millisecond
+	)
+
+	requested := make(chan int64, 10)
+	randInt63n = func(n int64) int64 {
+		requested <- n
+		return n - 1
+	}
+	defer func() {
+		randInt63n = rand.Int63n
+	}()
+
+	ticks := make([]time.Time, 0)
+	ctx, cancel := context.WithCancel(context.Background())
+	m := NewFuncManager()
+	start := time.Now()
+	m.RunEvery(ctx, interval, func(ctx context.Context, wrapperData *Data) {
+		ticks = append(ticks, time.Now())
+		if len(ticks) == 3 {
+			cancel()
+		}
+	}, WithOptionJitter(maxJitter))
+	<-ctx.Done()
+
+	err := m.Shutdown(context.Background())
+	if err != nil {
+		t.Errorf("unexpected shutdown error: %v", err)
+	}
+
+	prev := start
+	for _, tick := range ticks {
+		gap := tick.Sub(prev)
+		if gap < interval+maxJitter || gap > interval+maxJitter+100*time.Millisecond {
+			t.Errorf("tick is out of the jitter range, gap: %v", gap)
+		}
+		prev = tick
+	}
+
+	close(requested)
+	for n := range requested {
+		if n != int64(maxJitter)+1 {
+			t.Errorf("jitter should be bounded by max, got: %d", n)
+		}
+	}
+}
+
+func TestDataSetOnce(t *testing.T) {
+	data := &Data{}
+
+	err := data.SetOnce("abc", 123)
+	if err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	err = data.SetOnce("abc", 456)
+	if !errors.Is(err, ErrKeyExists) {
+		t.Errorf("second set should fail, got: %v", err)
+	}
+	if data.Get("abc") != 123 {
+		t.Errorf("value should stay the first one, got: %v", data.Get("abc"))
+	}
+
+	// nil value is still an existing key
+	err = data.SetOnce("nil-value", nil)
+	if err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	err = data.SetOnce("nil-value", 1)
+	if !errors.Is(err, ErrKeyExists) {
+		t.Errorf("second set should fail, got: %v", err)
+	}
+
+	if data.SetOnce(nil, 1) == nil {
+		t.Errorf("nil key should fail")
+	}
+	if data.SetOnce([]string{"a"}, 1) == nil {
+		t.Errorf("non comparable key should fail")
+	}
+}
+
+func TestRunAllE(t *testing.T) {
+	m := NewFuncManager()
+	errFailed := errors.New("failed")
+	cancelled := int32(0)
+
+	waitCancel := func(ctx context.Context, wrapperData *Data) error {
+		select {
+		case <-ctx.Done():
+			atomic.AddInt32(&cancelled, 1)
+			return ctx.Err()
+		case <-time.After(5 * time.Second):
+			return nil
+		}
+	}
+
+	err := m.RunAllE(context.Background(), waitCancel, func(ctx context.Context, wrapperData *Data) error {
+		time.Sleep(10 * time.Millisecond)
+		return errFailed
+	}, waitCancel)
+	if !errors.Is(err, errFailed) {
+		t.Errorf("first error should be returned, got: %v", err)
+	}
+	if cancelled != 2 {
+		t.Errorf("other functions should observe the cancellation, cancelled: %d", cancelled)
+	}
+
+	err = m.RunAllE(context.Background(), func(ctx context.Context, wrapperData *Data) error {
+		return nil
+	})
+	if err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	err = m.Shutdown(context.Background())
+	if err != nil {
+		t.Errorf("unexpected shutdown error: %v", err)
+	}
+
+	err = m.RunAllE(context.Background(), waitCancel)
+	if !errors.Is(err, ErrAlreadyShutdown) {
+		t.Errorf("should return ErrAlreadyShutdown, got: %v", err)
+	}
+}
+
+func TestMiddlewarePanicToError(t *testing.T) {
+	errPanic := errors.New("panic")
+	var gotStack []byte
+	m := NewFuncManager(WithMiddlewarePanicToError(func(recoverVal interface{}, stack []byte) error {
+		gotStack = stack
+		return fmt.Errorf("%w: %v", errPanic, recoverVal)
+	}))
+
+	err := m.RunE(context.Background(), func(ctx context.Context, wrapperData *Data) error {
+		panic("boom")
+	})
+	if !errors.Is(err, errPanic) || err.Error() != "panic: boom" {
+		t.Errorf("panic should be converted, got: %v", err)
+	}
+	if len(gotStack) == 0 {
+		t.Errorf("stack should be passed to the convert")
+	}
+
+	errReturned := errors.New("returned")
+	err = m.RunE(context.Background(), func(ctx context.Context, wrapperData *Data) error {
+		return errReturned
+	})
+	if !errors.Is(err, errReturned) {
+		t.Errorf("returned error should be reported, got: %v", err)
+	}
+
+	m2 := NewFuncManager(WithMiddlewarePanicToError(nil))
+	err = m2.RunE(context.Background(), func(ctx context.Context, wrapperData *Data) error {
+		panic("boom")
+	})
+	if err == nil || err.Error() != "panic: boom" {
+		t.Errorf("panic should be formatted, got: %v", err)
+	}
+
+	err = m.Shutdown(context.Background())
+	if err != nil {
+		t.Errorf("unexpected shutdown error: %v", err)
+	}
+	err = m.RunE(context.Background(), func(ctx context.Context, wrapperData *Data) error {
+		return nil
+	})
+	if !errors.Is(err, ErrAlreadyShutdown) {
+		t.Errorf("should return ErrAlreadyShutdown, got: %v", err)
+	}
+}
+
+func TestFuncManagerWithWorkers(t *testing.T) {
+	m := NewFuncManagerWithWorkers(3)
+
+	var (
+		running  int32
+		peak     int32
+		executed int32
+	)
+	for i := 0; i < 50; i++ {
+		m.RunAsync(context.Background(), func(ctx context.Context, wrapperData *Data) {
+			cur := atomic.AddInt32(&running, 1)
+			defer atomic.AddInt32(&running, -1)
+			for {
+				old := atomic.LoadInt32(&peak)
+				if cur <= old || atomic.CompareAndSwapInt32(&peak, old, cur) {
+					break
+				}
+			}
+			time.Sleep(time.Millisecond)
+			atomic.AddInt32(&executed, 1)
+		})
+	}
+
+	err := m.Shutdown(context.Background())
+	if err != nil {
+		t.Errorf("unexpected shutdown error: %v", err)
+	}
+	if executed != 50 {
+		t.Errorf("all jobs should be executed, executed: %d", executed)
+	}
+	if peak > 3 {
+		t.Errorf("jobs should be run by at most 3 workers, peak: %d", peak)
+	}
+
+	m.RunAsync(context.Background(), func(ctx context.Context, wrapperData *Data) {
+		t.Errorf("should not be executed after shutdown")
+	})
+}
+
+func TestChild(t *testing.T) {
+	parent := NewFuncManager()
+	child := parent.Child()
+	sibling := parent.Child()
+
+	// shutting down a child does not affect its siblings
+	err := sibling.Shutdown(context.Background())
+	if err != nil {
+		t.Errorf("unexpected shutdown error: %v", err)
+	}
+	select {
+	case <-child.Wait():
+		t.Errorf("child should not be shutdown by its sibling")
+	default:
+	}
+
+	cancelled := int32(0)
+	started := make(chan struct{})
+	child.RunAsync(context.Background(), func(ctx context.Context, wrapperData *Data) {
+		close(started)
+		<-ctx.Done()
+		time.Sleep(10 * time.Millisecond)
+		atomic.StoreInt32(&cancelled, 1)
+	})
+	<-started
+
+	// the parent waits for its children
+	err = parent.Shutdown(context.Background())
+	if err != nil {
+		t.Errorf("unexpected shutdown error: %v", err)
+	}
+	if atomic.LoadInt32(&cancelled) != 1 {
+		t.Errorf("child fn should be cancelled and waited by the parent shutdown")
+	}
+	select {
+	case <-child.Wait():
+	default:
+		t.Errorf("child should be shutdown by its parent")
+	}
+
+	late := parent.Child()
+	select {
+	case <-late.Wait():
+	default:
+		t.Errorf("child of a shutdown parent should be shutdown")
+	}
+	if err = WaitAllGoroutines(parent, time.Second); err != nil {
+		t.Errorf("the shutdown of the children should be counted, got: %v", err)
+	}
+}
+
+func TestChildInheritsConfig(t *testing.T) {
+	scheduled := int32(0)
+	parent := NewFuncManagerWithConfig(Config{
+		MaxConcurrency: 1,
+		Workers:        1,
+		Schedulers: []SchedulerMiddleware{func(next ScheduleFunc) ScheduleFunc {
+			return func(ctx context.Context, fn HandleFunc, opts ...Option) {
+				atomic.AddInt32(&scheduled, 1)
+				next(ctx, fn, opts...)
+			}
+		}},
+		DefaultIdentifier: "parent",
+	})
+	child := parent.Child()
+
+	release := make(chan struct{})
+	started := make(chan struct{})
+	parent.RunAsync(context.Background(), func(ctx context.Context, wrapperData *Data) {
+		close(started)
+		go func() {
+			time.Sleep(20 * time.Millisecond)
+			close(release)
+		}()
+		<-release
+	})
+	<-started
+
+	// the child is limited by the MaxConcurrency of the parent
+	var identifier string
+	child.Run(context.Background(), func(ctx context.Context, wrapperData *Data) {
+		identifier = GetIdentifier(wrapperData)
+	})
+	select {
+	case <-release:
+	default:
+		t.Errorf("child fn should wait for the parent fn")
+	}
+	if identifier != "parent" {
+		t.Errorf("unexpected identifier: %s", identifier)
+	}
+
+	// the async runs of the child go through the schedulers of the parent
+	done := make(chan struct{})
+	child.RunAsync(context.Background(), func(ctx context.Context, wrapperData *Data) {
+		close(done)
+	})
+	<-done
+	if n := atomic.LoadInt32(&scheduled); n != 2 {
+		t.Errorf("unexpected number of scheduled runs: %d", n)
+	}
+
+	if err := parent.Shutdown(context.Background()); err != nil {
+		t.Errorf("unexpected shutdown error: %v", err)
+	}
+	if err := WaitAllGoroutines(parent, time.Second); err != nil {
+		t.Errorf("unexpected goroutines: %v", err)
+	}
+}
+
+func TestSchedulerMiddleware(t *testing.T) {
+	submitted := int32(0)
+	quota := func(next ScheduleFunc) ScheduleFunc {
+		return func(ctx context.Context, fn HandleFunc, opts ...Option) {
+			if atomic.AddInt32(&submitted, 1) > 2 {
+				return
+			}
+			next(ctx, fn, opts...)
+		}
+	}
+	m := NewFuncManagerWithConfig(Config{Schedulers: []SchedulerMiddleware{quota}})
+
+	executed := int32(0)
+	for i := 0; i < 5; i++ {
+		m.RunAsync(context.Background(), func(ctx context.Context, wrapperData *Data) {
+			atomic.AddInt32(&executed, 1)
+		})
+	}
+
+	// the synchronous run is not scheduled
+	m.Run(context.Background(), func(ctx context.Context, wrapperData *Data) {
+		atomic.AddInt32(&executed, 1)
+	})
+
+	err := m.Shutdown(context.Background())
+	if err != nil {
+		t.Errorf("unexpected shutdown error: %v", err)
+	}
+	if submitted != 5 {
+		t.Errorf("all submissions should be counted, submitted: %d", submitted)
+	}
+	if executed != 3 {
+		t.Errorf("submissions over the quota should be rejected, executed: %d", executed)
+	}
+}
+
+func TestOptionStartTime(t *testing.T) {
+	m := NewFuncManager()
+
+	m.Run(context.Background(), func(ctx context.Context, wrapperData *Data) {
+		first, ok := Elapsed(wrapperData)
+		if !ok || first < 0 {
+			t.Errorf("elapsed should be recorded, got: %v %v", first, ok)
+		}
+		time.Sleep(5 * time.Millisecond)
+		second, _ := Elapsed(wrapperData)
+		if second <= first || second < 5*time.Millisecond {
+			t.Errorf("elapsed should increase, first: %v second: %v", first, second)
+		}
+	}, WithOptionStartTime())
+
+	m.Run(context.Background(), func(ctx context.Context, wrapperData *Data) {
+		if _, ok := Elapsed(wrapperData); ok {
+			t.Errorf("elapsed should not be recorded without the option")
+		}
+	})
+
+	err := m.Shutdown(context.Background())
+	if err != nil {
+		t.Errorf("unexpected shutdown error: %v", err)
+	}
+}
+
+func TestShutdownReport(t *testing.T) {
+	m := NewFuncManagerWithConfig(Config{MaxConcurrency: 1})
+	m.Run(context.Background(), func(ctx context.Context, wrapperData *Data) {}, WithOptionIdentifier("done"))
+
+	release := make(chan struct{})
+	defer close(release)
+	started := make(chan struct{})
+	m.RunAsync(context.Background(), func(ctx context.Context, wrapperData *Data) {
+		close(started)
+		<-release
+	}, WithOptionIdentifier("stuck"))
+	<-started
+	// waiting for the concurrency, so it is not running yet
+	m.RunAsync(context.Background(), func(ctx context.Context, wrapperData *Data) {}, WithOptionIdentifier("waiting"))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	identifiers, err := m.ShutdownReport(ctx)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("shutdown should time out, got: %v", err)
+	}
+	if len(identifiers) != 1 || identifiers[0] != "stuck" {
+		t.Errorf("stuck function should be reported, got: %v", identifiers)
+	}
+
+	identifiers, err = m.ShutdownReport(context.Background())
+	if !errors.Is(err, ErrAlreadyShutdown) || identifiers != nil {
+		t.Errorf("should return ErrAlreadyShutdown, got: %v %v", err, identifiers)
+	}
+}
+
+func TestPauseResume(t *testing.T) {
+	m := NewFuncManager()
+	executed := int32(0)
+
+	m.Pause()
+	for i := 0; i < 3; i++ {
+		m.RunAsync(context.Background(), func(ctx context.Context, wrapperData *Data) {
+			atomic.AddInt32(&executed, 1)
+		})
+	}
+
+	time.Sleep(20 * time.Millisecond)
+	if atomic.LoadInt32(&executed) != 0 {
+		t.Errorf("fn should not be executed while paused")
+	}
+
+	m.Resume()
+	err := m.Shutdown(context.Background())
+	if err != nil {
+		t.Errorf("unexpected shutdown error: %v", err)
+	}
+	if executed != 3 {
+		t.Errorf("held fn should be executed after resume, executed: %d", executed)
+	}
+
+	// the held runs are cancelled by the shutdown
+	m = NewFuncManager()
+	m.Pause()
+	m.RunAsync(context.Background(), func(ctx context.Context, wrapperData *Data) {
+		t.Errorf("held fn should not be executed after shutdown")
+	}, WithOptionNoShutdownWatch())
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	err = m.Shutdown(ctx)
+	if err != nil {
+		t.Errorf("unexpected shutdown error: %v", err)
+	}
+}
+
+func TestNamedMiddleware(t *testing.T) {
+	var order []string
+	record := func(name string) Middleware {
+		return func(next HandleFunc) HandleFunc {
+			return func(ctx context.Context, wrapperData *Data) {
+				order = append(order, name)
+				next(ctx, wrapperData)
+			}
+		}
+	}
+	m := NewFuncManager(
+		NamedMiddleware("first", record("first")),
+		record("unnamed"),
+		NamedMiddleware("second", record("second")),
+		NamedMiddleware("third", record("third")),
+	)
+
+	names := m.Middlewares()
+	expected := []string{"first", "", "second", "third"}
+	if len(names) != len(expected) {
+		t.Fatalf("unexpected middlewares: %v", names)
+	}
+	for i := range expected {
+		if names[i] != expected[i] {
+			t.Errorf("unexpected middlewares: %v", names)
+		}
+	}
+	if len(order) != 0 {
+		t.Errorf("middlewares should not be executed by the introspection, executed: %v", order)
+	}
+
+	// the reported order matches the execution order
+	m.Run(context.Background(), func(ctx context.Context, wrapperData *Data) {})
+	executed := []string{"first", "unnamed", "second", "third"}
+	for i := range executed {
+		if order[i] != executed[i] {
+			t.Errorf("unexpected execution order: %v", order)
+		}
+	}
+
+	// the named middlewares of a child follow the inherited ones
+	child := m.Child(NamedMiddleware("child", record("child")))
+	if names := child.Middlewares(); fmt.Sprint(names) != "[first  second third child]" {
+		t.Errorf("unexpected middlewares of the child: %v", names)
+	}
+
+	err := m.Shutdown(context.Background())
+	if err != nil {
+		t.Errorf("unexpected shutdown error: %v", err)
+	}
+}
+
+func TestOnShutdownDrain(t *testing.T) {
+	drained := int32(0)
+	cancelled := int32(0)
+	drainCalled := make(chan struct{})
+	m := NewFuncManagerWithConfig(Config{OnShutdownDrain: func() {
+		if atomic.AddInt32(&drained, 1) == 1 {
+			close(drainCalled)
+		}
+	}})
+
+	started := make(chan struct{})
+	m.RunAsync(context.Background(), func(ctx context.Context, wrapperData *Data) {
+		close(started)
+		<-ctx.Done()
+		// the hook is called before waiting for the running functions
+		select {
+		case <-drainCalled:
+		case <-time.After(time.Second):
+			t.Errorf("drain hook should be called before waiting")
+		}
+		atomic.StoreInt32(&cancelled, 1)
+	})
+	<-started
+
+	err := m.Shutdown(context.Background())
+	if err != nil {
+		t.Errorf("unexpected shutdown error: %v", err)
+	}
+	_ = m.Shutdown(context.Background())
+
+	if drained != 1 {
+		t.Errorf("drain hook should be called once, called: %d", drained)
+	}
+	if cancelled != 1 {
+		t.Errorf("fn should be waited")
+	}
+}
+
+func TestWaitAllGoroutines(t *testing.T) {
+	m := NewFuncManagerWithWorkers(2)
+	for i := 0; i < 10; i++ {
+		m.RunAsync(context.Background(), func(ctx context.Context, wrapperData *Data) {})
+		m.Run(context.Background(), func(ctx context.Context, wrapperData *Data) {})
+	}
+	m.RunEvery(context.Background(), time.Millisecond, func(ctx context.Context, wrapperData *Data) {})
+
+	err := m.Shutdown(context.Background())
+	if err != nil {
+		t.Errorf("unexpected shutdown error: %v", err)
+	}
+	// the watchers, workers and loops are done after the shutdown
+	err = WaitAllGoroutines(m, time.Second)
+	if err != nil {
+		t.Errorf("goroutines should be done: %v", err)
+	}
+
+	// the stuck fn outlives the shutdown
+	m = NewFuncManager()
+	release := make(chan struct{})
+	m.RunAsync(context.Background(), func(ctx context.Context, wrapperData *Data) {
+		<-release
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	_ = m.Shutdown(ctx)
+
+	err = WaitAllGoroutines(m, 20*time.Millisecond)
+	if !errors.Is(err, ErrGoroutineLeak) {
+		t.Errorf("should return ErrGoroutineLeak, got: %v", err)
+	}
+
+	close(release)
+	err = WaitAllGoroutines(m, time.Second)
+	if err != nil {
+		t.Errorf("goroutines should be done: %v", err)
+	}
+}
+
+func TestMaxLifetime(t *testing.T) {
+	m := NewFuncManagerWithConfig(Config{}, WithMaxLifetime(20*time.Millisecond))
+
+	cancelled := int32(0)
+	m.RunAsync(context.Background(), func(ctx context.Context, wrapperData *Data) {
+		<-ctx.Done()
+		atomic.StoreInt32(&cancelled, 1)
+	})
+
+	select {
+	case <-m.Wait():
+	case <-time.After(5 * time.Second):
+		t.Fatal("manager should be shutdown after its lifetime")
+	}
+	if err := m.WaitCause(); err != nil {
+		t.Errorf("unexpected shutdown cause: %v", err)
+	}
+	if atomic.LoadInt32(&cancelled) != 1 {
+		t.Errorf("fn should be cancelled by the automatic shutdown")
+	}
+	if err := m.Shutdown(context.Background()); !errors.Is(err, ErrAlreadyShutdown) {
+		t.Errorf("should return ErrAlreadyShutdown, got: %v", err)
+	}
+}
+
+func TestOptionResultChannel(t *testing.T) {
+	m := NewFuncManager(WithMiddlewareRecoverPanic(nil))
+	results := make(chan interface{}, 1)
+
+	m.RunAsync(context.Background(), func(ctx context.Context, wrapperData *Data) {
+		SetResult(wrapperData, 42)
+	}, WithOptionResultChannel(results))
+
+	select {
+	case result := <-results:
+		if result != 42 {
+			t.Errorf("unexpected result: %v", result)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("result should be sent")
+	}
+
+	// nil is sent on panic
+	m2 := NewFuncManager()
+	func() {
+		defer func() {
+			_ = recover()
+		}()
+		m2.Run(context.Background(), func(ctx context.Context, wrapperData *Data) {
+			SetResult(wrapperData, 42)
+			panic("boom")
+		}, WithOptionResultChannel(results))
+	}()
+	if result := <-results; result != nil {
+		t.Errorf("nil should be sent on panic, got: %v", result)
+	}
+
+	err := m.Shutdown(context.Background())
+	if err != nil {
+		t.Errorf("unexpected shutdown error: %v", err)
+	}
+
+	// the send without receiver does not hold the shutdown
+	m = NewFuncManager()
+	done := make(chan struct{})
+	m.RunAsync(context.Background(), func(ctx context.Context, wrapperData *Data) {
+		SetResult(wrapperData, 42)
+		close(done)
+	}, WithOptionResultChannel(make(chan interface{})))
+	<-done
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	err = m.Shutdown(ctx)
+	if err != nil {
+		t.Errorf("unexpected shutdown error: %v", err)
+	}
+}
+
+func TestWithValueMiddleware(t *testing.T) {
+	type ctxKey string
+
+	m := NewFuncManager(
+		WithValueMiddleware(ctxKey("outer"), "a"),
+		WithValueMiddleware(ctxKey("inner"), "b"),
+		func(next HandleFunc) HandleFunc {
+			return func(ctx context.Context, wrapperData *Data) {
+				// the middleware below the injecting ones sees the outer value
+				if ctx.Value(ctxKey("outer")) != "a" {
+					t.Errorf("inner middleware should see the outer value, got: %v", ctx.Value(ctxKey("outer")))
+				}
+				next(ctx, wrapperData)
+			}
+		},
+	)
+
+	called := false
+	m.Run(context.Background(), func(ctx context.Context, wrapperData *Data) {
+		called = true
+		if val := ctx.Value(ctxKey("outer")); val != "a" {
+			t.Errorf("unexpected outer value: %v", val)
+		}
+		if val := ctx.Value(ctxKey("inner")); val != "b" {
+			t.Errorf("unexpected inner value: %v", val)
+		}
+	})
+	if !called {
+		t.Error("fn should be called")
+	}
+
+	// the invalid key is passed through
+	m2 := NewFuncManager(WithValueMiddleware(nil, 1), WithValueMiddleware([]string{"a"}, 1))
+	called = false
+	m2.Run(context.Background(), func(ctx context.Context, wrapperData *Data) {
+		called = true
+	})
+	if !called {
+		t.Error("fn should be called")
+	}
+
+	err := m.Shutdown(context.Background())
+	if err != nil {
+		t.Errorf("unexpected shutdown error: %v", err)
+	}
+	err = m2.Shutdown(context.Background())
+	if err != nil {
+		t.Errorf("unexpected shutdown error: %v", err)
+	}
+}
+
+func TestRunBatch(t *testing.T) {
+	m := NewFuncManager()
+
+	var (
+		mu  sync.Mutex
+		ids []string
+	)
+	record := func(ctx context.Context, wrapperData *Data) {
+		mu.Lock()
+		defer mu.Unlock()
+		ids = append(ids, GetIdentifier(wrapperData))
+	}
+	fns := []HandleFunc{record, record, nil, record}
+
+	m.RunBatch(context.Background(), fns, WithOptionIdentifier("batch"))
+	mu.Lock()
+	if len(ids) != 3 {
+		t.Errorf("all fns should run before RunBatch returns, got: %d", len(ids))
+	}
+	mu.Unlock()
+
+	m.RunAsyncBatch(context.Background(), fns, WithOptionIdentifier("async-batch"))
+
+	err := m.Shutdown(context.Background())
+	if err != nil {
+		t.Errorf("unexpected shutdown error: %v", err)
+	}
+
+	if len(ids) != 6 {
+		t.Fatalf("unexpected number of runs: %d", len(ids))
+	}
+	for i, id := range ids {
+		expected := "batch"
+		if i >= 3 {
+			expected = "async-batch"
+		}
+		if id != expected {
+			t.Errorf("unexpected identifier of run %d: %s", i, id)
+		}
+	}
+}
+
+func TestOptionOnComplete(t *testing.T) {
+	m := NewFuncManager()
+
+	type completion struct {
+		id       string
+		panicked bool
+	}
+	completions := make(chan completion, 2)
+	onComplete := WithOptionOnComplete(func(data *Data, panicked bool) {
+		completions <- completion{id: GetIdentifier(data), panicked: panicked}
+	})
+
+	m.Run(context.Background(), func(ctx context.Context, wrapperData *Data) {}, WithOptionIdentifier("normal"), onComplete)
+	if c := <-completions; c.id != "normal" || c.panicked {
+		t.Errorf("unexpected completion: %+v", c)
+	}
+
+	func() {
+		defer func() {
+			if recover() == nil {
+				t.Error("panic should not be recovered")
+			}
+		}()
+		m.Run(context.Background(), func(ctx context.Context, wrapperData *Data) {
+			panic("boom")
+		}, WithOptionIdentifier("panicking"), onComplete)
+	}()
+	if c := <-completions; c.id != "panicking" || !c.panicked {
+		t.Errorf("unexpected completion: %+v", c)
+	}
+
+	err := m.Shutdown(context.Background())
+	if err != nil {
+		t.Errorf("unexpected shutdown error: %v", err)
+	}
+}
+
+func TestStopAcceptingDrain(t *testing.T) {
+	var rejected int32
+	m := NewFuncManagerWithConfig(Config{
+		OnRejected: func(ctx context.Context, data *Data) {
+			atomic.AddInt32(&rejected, 1)
+		},
+	})
+	child := m.Child()
+
+	release := make(chan struct{})
+	var finished int32
+	m.RunAsync(context.Background(), func(ctx context.Context, wrapperData *Data) {
+		<-release
+		if ctx.Err() != nil {
+			t.Error("the running fn should not be cancelled by StopAccepting or Drain")
+		}
+		atomic.AddInt32(&finished, 1)
+	})
+
+	m.StopAccepting()
+
+	m.Run(context.Background(), func(ctx context.Context, wrapperData *Data) {
+		t.Error("fn should be rejected")
+	})
+	child.RunAsync(context.Background(), func(ctx context.Context, wrapperData *Data) {
+		t.Error("fn of the child should be rejected")
+	})
+	if n := atomic.LoadInt32(&rejected); n != 2 {
+		t.Errorf("unexpected number of rejected runs: %d", n)
+	}
+
+	var goroutines int32
+	for i := 0; i < 3; i++ {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		if err := m.Drain(ctx); !errors.Is(err, context.DeadlineExceeded) {
+			t.Errorf("drain should time out while the fn is running, got: %v", err)
+		}
+		cancel()
+
+		// the timed out drains share a single goroutine waiting for the fn
+		n := atomic.LoadInt32(&m.(*funcManager).goroutines)
+		if i > 0 && n != goroutines {
+			t.Errorf("timed out drains should not leave a goroutine each, goroutines: %d, before: %d", n, goroutines)
+		}
+		goroutines = n
+	}
+
+	close(release)
+	if err := m.Drain(context.Background()); err != nil {
+		t.Errorf("unexpected drain error: %v", err)
+	}
+	if atomic.LoadInt32(&finished) != 1 {
+		t.Error("fn should be finished after drain")
+	}
+
+	// the drained manager is shutdown gracefully
+	select {
+	case <-m.Wait():
+	case <-time.After(5 * time.Second):
+		t.Fatal("wait should be released after drain")
+	}
+	if err := m.WaitCause(); err != nil {
+		t.Errorf("unexpected wait cause: %v", err)
+	}
+	if mode := m.Stats().ShutdownMode; mode != ShutdownModeGraceful {
+		t.Errorf("unexpected shutdown mode: %s", mode)
+	}
+	if err := m.Shutdown(context.Background()); !errors.Is(err, ErrAlreadyShutdown) {
+		t.Errorf("unexpected shutdown error: %v", err)
+	}
+	if err := WaitAllGoroutines(m, time.Second); err != nil {
+		t.Errorf("unexpected goroutines: %v", err)
+	}
+}
+
+func TestPeakConcurrency(t *testing.T) {
+	m := NewFuncManager()
+
+	var (
+		started sync.WaitGroup
+		release = make(chan struct{})
+	)
+	started.Add(3)
+	for i := 0; i < 3; i++ {
+		m.RunAsync(context.Background(), func(ctx context.Context, wrapperData *Data) {
+			started.Done()
+			<-release
+		})
+	}
+	started.Wait()
+	if running := m.Running(); running != 3 {
+		t.Errorf("unexpected running: %d", running)
+	}
+	close(release)
+	for deadline := time.Now().Add(5 * time.Second); m.Running() > 0 && time.Now().Before(deadline); {
+		time.Sleep(time.Millisecond)
+	}
+
+	// the sequential runs do not overlap
+	for i := 0; i < 2; i++ {
+		m.Run(context.Background(), func(ctx context.Context, wrapperData *Data) {})
+	}
+
+	err := m.Shutdown(context.Background())
+	if err != nil {
+		t.Errorf("unexpected shutdown error: %v", err)
+	}
+	if running := m.Running(); running != 0 {
+		t.Errorf("unexpected running after shutdown: %d", running)
+	}
+	if peak := m.PeakConcurrency(); peak != 3 {
+		t.Errorf("unexpected peak concurrency: %d", peak)
+	}
+}
+
+func TestMiddlewareContextTimeout(t *testing.T) {
+	type timeoutKey struct{}
+
+	m := NewFuncManager(WithMiddlewareContextTimeout(timeoutKey{}))
+	withTimeout := func(d interface{}) Option {
+		return func(wrapperData *Data) {
+			_ = wrapperData.Set(timeoutKey{}, d)
+		}
+	}
+
+	m.Run(context.Background(), func(ctx context.Context, wrapperData *Data) {
+		select {
+		case <-ctx.Done():
+			if !errors.Is(ctx.Err(), context.DeadlineExceeded) {
+				t.Errorf("unexpected ctx error: %v", ctx.Err())
+			}
+		case <-time.After(5 * time.Second):
+			t.Error("ctx should be expired")
+		}
+	}, withTimeout(10*time.Millisecond))
+
+	// absent or wrong type is passed through
+	for _, opt := range []Option{nil, withTimeout("10ms")} {
+		m.Run(context.Background(), func(ctx context.Context, wrapperData *Data) {
+			if _, ok := ctx.Deadline(); ok {
+				t.Error("ctx should not have a deadline")
+			}
+		}, opt)
+	}
+
+	err := m.Shutdown(context.Background())
+	if err != nil {
+		t.Errorf("unexpected shutdown error: %v", err)
+	}
+}
+
+func TestInitialData(t *testing.T) {
+	type ctxKey string
+
+	m := NewFuncManager()
+
+	called := false
+	m.Run(context.Background(), func(ctx context.Context, wrapperData *Data) {
+		called = true
+		if val := wrapperData.Get("request-id"); val != "abc" {
+			t.Errorf("unexpected request-id: %v", val)
+		}
+		if val := wrapperData.Get(ctxKey("user")); val != 42 {
+			t.Errorf("unexpected user: %v", val)
+		}
+		if val := wrapperData.Get(nil); val != nil {
+			t.Errorf("invalid key should be skipped, got: %v", val)
+		}
+	}, WithInitialData(map[interface{}]interface{}{
+		"request-id":   "abc",
+		ctxKey("user"): 42,
+		// an un-comparable key can not be stored in the map at all, nil is the invalid key left to skip
+		nil: "invalid",
+	}))
+	if !called {
+		t.Error("fn should be called")
+	}
+
+	err := m.Shutdown(context.Background())
+	if err != nil {
+		t.Errorf("unexpected shutdown error: %v", err)
+	}
+}
+
+func TestOptionTraceID(t *testing.T) {
+	m := NewFuncManager()
+
+	var traceIDs []string
+	for i := 0; i < 2; i++ {
+		m.Run(context.Background(), func(ctx context.Context, wrapperData *Data) {
+			traceID := TraceID(wrapperData)
+			if len(traceID) != 32 {
+				t.Errorf("unexpected trace id: %q", traceID)
+			}
+			if fromCtx := TraceIDFromContext(ctx); fromCtx != traceID {
+				t.Errorf("trace id of the ctx should match, got: %q %q", fromCtx, traceID)
+			}
+			if again := TraceID(wrapperData); again != traceID {
+				t.Errorf("trace id should be stable, got: %q %q", again, traceID)
+			}
+			traceIDs = append(traceIDs, traceID)
+		}, WithOptionTraceID(), WithOptionTraceID())
+	}
+	if traceIDs[0] == traceIDs[1] {
+		t.Errorf("trace id should be unique per run, got: %q", traceIDs[0])
+	}
+
+	m.Run(context.Background(), func(ctx context.Context, wrapperData *Data) {
+		if TraceID(wrapperData) != "" || TraceIDFromContext(ctx) != "" {
+			t.Error("trace id should not be set without the option")
+		}
+	})
+
+	// the nested run shares the trace id of its parent
+	m.Run(context.Background(), func(ctx context.Context, parentData *Data) {
+		m.Run(ctx, func(ctx context.Context, wrapperData *Data) {
+			if TraceID(wrapperData) != TraceID(parentData) || TraceIDFromContext(ctx) != TraceID(parentData) {
+				t.Errorf("nested run should share the trace id, got: %q %q", TraceID(wrapperData), TraceID(parentData))
+			}
+		}, WithOptionTraceID())
+	}, WithOptionTraceID())
+
+	err := m.Shutdown(context.Background())
+	if err != nil {
+		t.Errorf("unexpected shutdown error: %v", err)
+	}
+}
+
+func TestDefaultIdentifier(t *testing.T) {
+	m := NewFuncManagerWithConfig(Config{}, WithDefaultIdentifier("billing"))
+
+	m.Run(context.Background(), func(ctx context.Context, wrapperData *Data) {
+		if identifier := GetIdentifier(wrapperData); identifier != "billing" {
+			t.Errorf("unexpected default identifier: %s", identifier)
+		}
+	})
+	m.Run(context.Background(), func(ctx context.Context, wrapperData *Data) {
+		if identifier := GetIdentifier(wrapperData); identifier != "invoice" {
+			t.Errorf("explicit identifier should override the default, got: %s", identifier)
+		}
+	}, WithOptionIdentifier("invoice"))
+
+	// inherited by the child
+	m.Child().Run(context.Background(), func(ctx context.Context, wrapperData *Data) {
+		if identifier := GetIdentifier(wrapperData); identifier != "billing" {
+			t.Errorf("unexpected default identifier of the child: %s", identifier)
+		}
+	})
+
+	err := m.Shutdown(context.Background())
+	if err != nil {
+		t.Errorf("unexpected shutdown error: %v", err)
+	}
+}
+
+func TestDataGetOrSet(t *testing.T) {
+	data := &Data{}
+
+	var (
+		calls int32
+		wg    sync.WaitGroup
+	)
+	results := make([]interface{}, 10)
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			val, err := data.GetOrSet("lazy", func() interface{} {
+				return atomic.AddInt32(&calls, 1)
+			})
+			if err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+			results[i] = val
+		}(i)
+	}
+	wg.Wait()
+
+	if calls != 1 {
+		t.Errorf("factory should be called once, got: %d", calls)
+	}
+	for _, result := range results {
+		if result != int32(1) {
+			t.Errorf("unexpected result: %v", result)
+		}
+	}
+
+	if _, err := data.GetOrSet(nil, func() interface{} { return 1 }); err == nil {
+		t.Error("nil key should be rejected")
+	}
+}
+
+func TestStatsShutdownMode(t *testing.T) {
+	m := NewFuncManager()
+	m.Run(context.Background(), func(ctx context.Context, wrapperData *Data) {})
+	if stats := m.Stats(); stats.ShutdownMode != ShutdownModeNone || stats.PeakConcurrency != 1 {
+		t.Errorf("unexpected stats: %+v", stats)
+	}
+	if err := m.Shutdown(context.Background()); err != nil {
+		t.Errorf("unexpected shutdown error: %v", err)
+	}
+	if mode := m.Stats().ShutdownMode; mode != ShutdownModeGraceful {
+		t.Errorf("unexpected shutdown mode: %v", mode)
+	}
+
+	// the fn ignores the cancellation, so the shutdown is forced
+	m = NewFuncManager()
+	release := make(chan struct{})
+	started := make(chan struct{})
+	m.RunAsync(context.Background(), func(ctx context.Context, wrapperData *Data) {
+		close(started)
+		<-release
+	})
+	<-started
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := m.Shutdown(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("unexpected shutdown error: %v", err)
+	}
+	if stats := m.Stats(); stats.ShutdownMode != ShutdownModeForced || stats.Running != 1 {
+		t.Errorf("unexpected stats: %+v", stats)
+	}
+	if mode := m.Stats().ShutdownMode.String(); mode != "forced" {
+		t.Errorf("unexpected shutdown mode: %s", mode)
+	}
+	close(release)
+}
+
+func TestMiddlewareBulkhead(t *testing.T) {
+	limits := map[string]int{"flood": 2}
+	m := NewFuncManager(WithMiddlewareBulkhead(limits, 0))
+	// the later change is not seen by the middleware
+	limits["flood"] = 5
+
+	var (
+		running    int32
+		maxRunning int32
+		release    = make(chan struct{})
+	)
+	for i := 0; i < 10; i++ {
+		m.RunAsync(context.Background(), func(ctx context.Context, wrapperData *Data) {
+			cur := atomic.AddInt32(&running, 1)
+			defer atomic.AddInt32(&running, -1)
+			for {
+				peak := atomic.LoadInt32(&maxRunning)
+				if cur <= peak || atomic.CompareAndSwapInt32(&maxRunning, peak, cur) {
+					break
+				}
+			}
+			<-release
+		}, WithOptionIdentifier("flood"))
+	}
+
+	// the other identifier still runs promptly
+	done := make(chan struct{})
+	m.RunAsync(context.Background(), func(ctx context.Context, wrapperData *Data) {
+		close(done)
+	}, WithOptionIdentifier("other"))
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Error("other identifier should not be starved")
+	}
+
+	// wait for the flood to reach its limit
+	deadline := time.Now().Add(5 * time.Second)
+	for atomic.LoadInt32(&running) < 2 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	close(release)
+	err := m.Shutdown(context.Background())
+	if err != nil {
+		t.Errorf("unexpected shutdown error: %v", err)
+	}
+	if maxRunning != 2 {
+		t.Errorf("unexpected max concurrent runs of the flood: %d", maxRunning)
+	}
+
+	// skip instead of waiting
+	m = NewFuncManager(WithMiddlewareBulkheadSkip(nil, 1))
+	block := make(chan struct{})
+	started := make(chan struct{})
+	m.RunAsync(context.Background(), func(ctx context.Context, wrapperData *Data) {
+		close(started)
+		<-block
+	}, WithOptionIdentifier("job"))
+	<-started
+
+	var data *Data
+	m.Run(context.Background(), func(ctx context.Context, wrapperData *Data) {
+		t.Error("fn exceeding the limit should be skipped")
+	}, WithOptionIdentifier("job"), func(wrapperData *Data) {
+		data = wrapperData
+	})
+	if skipped, reason := WasSkipped(data); !skipped || reason != "bulkhead limit reached" {
+		t.Errorf("run should be skipped, got: %v %s", skipped, reason)
+	}
+
+	close(block)
+	err = m.Shutdown(context.Background())
+	if err != nil {
+		t.Errorf("unexpected shutdown error: %v", err)
+	}
+}
+
+func TestMiddlewareBulkheadIdleRemoved(t *testing.T) {
+	limiter := newBulkheadLimiter(nil, 1)
+	m := NewFuncManager(limiter.middleware(false))
+
+	release := make(chan struct{})
+	started := make(chan struct{})
+	m.RunAsync(context.Background(), func(ctx context.Context, wrapperData *Data) {
+		close(started)
+		<-release
+	}, WithOptionIdentifier("busy"))
+	<-started
+	for i := 0; i < 100; i++ {
+		m.Run(context.Background(), func(ctx context.Context, wrapperData *Data) {}, WithOptionIdentifier(fmt.Sprint("key-", i)))
+	}
+
+	// only the identifier still running is kept
+	limiter.mu.Lock()
+	entries := len(limiter.entries)
+	limiter.mu.Unlock()
+	if entries != 1 {
+		t.Errorf("idle identifiers should be removed, entries: %d", entries)
+	}
+
+	close(release)
+	err := m.Shutdown(context.Background())
+	if err != nil {
+		t.Errorf("unexpected shutdown error: %v", err)
+	}
+	limiter.mu.Lock()
+	defer limiter.mu.Unlock()
+	if len(limiter.entries) != 0 {
+		t.Errorf("idle identifiers should be removed, entries: %d", len(limiter.entries))
+	}
+}
+
+func TestSetMiddlewares(t *testing.T) {
+	var (
+		mu    sync.Mutex
+		chain []string
+	)
+	record := func(name string) Middleware {
+		return func(next HandleFunc) HandleFunc {
+			return func(ctx context.Context, wrapperData *Data) {
+				next(ctx, wrapperData)
+				mu.Lock()
+				chain = append(chain, name)
+				mu.Unlock()
+			}
+		}
+	}
+
+	m := NewFuncManager(NamedMiddleware("a", record("a")))
+	m.Run(context.Background(), func(ctx context.Context, wrapperData *Data) {
+		// the started run keeps its chain
+		m.SetMiddlewares(NamedMiddleware("b", record("b")))
+	})
+	m.Run(context.Background(), func(ctx context.Context, wrapperData *Data) {})
+
+	if fmt.Sprint(chain) != "[a b]" {
+		t.Errorf("unexpected chain: %v", chain)
+	}
+	if names := m.Middlewares(); len(names) != 1 || names[0] != "b" {
+		t.Errorf("unexpected middlewares: %v", names)
+	}
+
+	err := m.Shutdown(context.Background())
+	if err != nil {
+		t.Errorf("unexpected shutdown error: %v", err)
+	}
+}
+
+func TestShutdownForcedAbandonsQueuedJobs(t *testing.T) {
+	var (
+		rejectedMu sync.Mutex
+		rejected   []*Data
+	)
+	m := NewFuncManagerWithConfig(Config{
+		Workers: 1,
+		OnRejected: func(ctx context.Context, data *Data) {
+			rejectedMu.Lock()
+			defer rejectedMu.Unlock()
+			rejected = append(rejected, data)
+		},
+	})
+
+	release := make(chan struct{})
+	started := make(chan struct{})
+	m.RunAsync(context.Background(), func(ctx context.Context, wrapperData *Data) {
+		close(started)
+		<-release
+	})
+	<-started
+
+	executed := int32(0)
+	result := make(chan interface{}, 1)
+	m.RunAsync(context.Background(), func(ctx context.Context, wrapperData *Data) {
+		atomic.AddInt32(&executed, 1)
+	}, WithOptionIdentifier("queued"), WithOptionResultChannel(result))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	err := m.Shutdown(ctx)
+	if err != context.DeadlineExceeded {
+		t.Errorf("invalid shutdown error, got: %v", err)
+	}
+
+	select {
+	case v := <-result:
+		if v != nil {
+			t.Errorf("invalid result of the queued job, got: %v", v)
+		}
+	default:
+		t.Errorf("the waiter of the queued job should be notified")
+	}
+
+	rejectedMu.Lock()
+	if len(rejected) != 1 || GetIdentifier(rejected[0]) != "queued" {
+		t.Errorf("the queued job should be rejected, got: %v", rejected)
+	} else if rejected[0].Get(keyError) != context.DeadlineExceeded {
+		t.Errorf("invalid error of the queued job, got: %v", rejected[0].Get(keyError))
+	}
+	rejectedMu.Unlock()
+
+	close(release)
+	if err = WaitAllGoroutines(m, time.Second); err != nil {
+		t.Errorf("unexpected goroutine leak: %v", err)
+	}
+	if atomic.LoadInt32(&executed) != 0 {
+		t.Errorf("the queued job should not be executed")
+	}
+}
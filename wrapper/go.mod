@@ -1,3 +1,3 @@
 module github.com/anantadwi13/go-sdk/wrapper
 
-go 1.14
+go 1.18
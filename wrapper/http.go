@@ -0,0 +1,92 @@
+package wrapper
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+)
+
+var errHijackNotSupported = errors.New("hijack is not supported by the response writer")
+
+// HTTPHandler will adapt the fn into http.Handler, each request is run synchronously through the m,
+// so the middlewares like the panic recovery are applied. The ctx of the fn is derived from the request context.
+// When the fn does not return normally, e.g. its panic is recovered by a middleware, 500 is responded,
+// and 503 is responded when the fn is not run at all, e.g. the m is shutdown.
+// Nothing is responded when the fn has already written the header
+func HTTPHandler(
+	m FuncManager, fn func(ctx context.Context, w http.ResponseWriter, r *http.Request, data *Data), opts ...Option,
+) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rw := &responseWriter{ResponseWriter: w}
+		started, returned := false, false
+
+		m.Run(r.Context(), func(ctx context.Context, wrapperData *Data) {
+			started = true
+			fn(ctx, rw, r.WithContext(ctx), wrapperData)
+			returned = true
+		}, opts...)
+
+		if rw.wroteHeader {
+			return
+		}
+		switch {
+		case !started:
+			http.Error(w, http.StatusText(http.StatusServiceUnavailable), http.StatusServiceUnavailable)
+		case !returned:
+			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		}
+	})
+}
+
+// responseWriter records whether the header is written. http.Flusher, http.Hijacker and io.ReaderFrom are forwarded
+// to the underlying http.ResponseWriter, the other interfaces are reachable by Unwrap
+type responseWriter struct {
+	http.ResponseWriter
+	wroteHeader bool
+}
+
+func (w *responseWriter) WriteHeader(statusCode int) {
+	w.wroteHeader = true
+	w.ResponseWriter.WriteHeader(statusCode)
+}
+
+func (w *responseWriter) Write(p []byte) (int, error) {
+	w.wroteHeader = true
+	return w.ResponseWriter.Write(p)
+}
+
+func (w *responseWriter) Flush() {
+	if flusher, ok := w.ResponseWriter.(http.Flusher); ok {
+		w.wroteHeader = true
+		flusher.Flush()
+	}
+}
+
+func (w *responseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	hijacker, ok := w.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, errHijackNotSupported
+	}
+	conn, rw, err := hijacker.Hijack()
+	if err == nil {
+		// the connection is taken over, nothing can be responded anymore
+		w.wroteHeader = true
+	}
+	return conn, rw, err
+}
+
+func (w *responseWriter) ReadFrom(r io.Reader) (int64, error) {
+	w.wroteHeader = true
+	if readerFrom, ok := w.ResponseWriter.(io.ReaderFrom); ok {
+		return readerFrom.ReadFrom(r)
+	}
+	return io.Copy(w.ResponseWriter, r)
+}
+
+// Unwrap will return the underlying http.ResponseWriter, it is used by http.ResponseController
+func (w *responseWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
@@ -0,0 +1,139 @@
+package wrapper
+
+import (
+	"context"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestHTTPHandler(t *testing.T) {
+	var recovered interface{}
+	m := NewFuncManager(WithMiddlewareRecoverPanic(func(recoverVal interface{}, wrapperData *Data) {
+		recovered = recoverVal
+	}))
+
+	type ctxKey struct{}
+	handler := HTTPHandler(m, func(ctx context.Context, w http.ResponseWriter, r *http.Request, data *Data) {
+		if r.URL.Path == "/panic" {
+			panic("boom")
+		}
+		if ctx.Value(ctxKey{}) != "req" {
+			t.Error("ctx should be derived from the request context")
+		}
+		if GetIdentifier(data) != "http" {
+			t.Errorf("unexpected identifier: %s", GetIdentifier(data))
+		}
+		_, _ = w.Write([]byte("ok"))
+	}, WithOptionIdentifier("http"))
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		handler.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), ctxKey{}, "req")))
+	}))
+	defer server.Close()
+
+	resp, err := http.Get(server.URL + "/ok")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	body, _ := ioutil.ReadAll(resp.Body)
+	_ = resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || string(body) != "ok" {
+		t.Errorf("unexpected response: %d %s", resp.StatusCode, body)
+	}
+
+	resp, err = http.Get(server.URL + "/panic")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	_ = resp.Body.Close()
+	if resp.StatusCode != http.StatusInternalServerError {
+		t.Errorf("unexpected status code: %d", resp.StatusCode)
+	}
+	if recovered != "boom" {
+		t.Errorf("panic should be recovered by the middleware, got: %v", recovered)
+	}
+
+	err = m.Shutdown(context.Background())
+	if err != nil {
+		t.Errorf("unexpected shutdown error: %v", err)
+	}
+
+	resp, err = http.Get(server.URL + "/ok")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	_ = resp.Body.Close()
+	if resp.StatusCode != http.StatusServiceUnavailable {
+		t.Errorf("unexpected status code: %d", resp.StatusCode)
+	}
+}
+
+func TestHTTPHandlerResponseWriter(t *testing.T) {
+	m := NewFuncManager()
+	defer func() {
+		_ = m.Shutdown(context.Background())
+	}()
+
+	handler := HTTPHandler(m, func(ctx context.Context, w http.ResponseWriter, r *http.Request, data *Data) {
+		if uw, ok := w.(interface{ Unwrap() http.ResponseWriter }); !ok || uw.Unwrap() == nil {
+			t.Error("response writer should be unwrappable")
+		}
+		switch r.URL.Path {
+		case "/hijack":
+			hijacker, ok := w.(http.Hijacker)
+			if !ok {
+				t.Error("response writer should implement http.Hijacker")
+				return
+			}
+			conn, rw, err := hijacker.Hijack()
+			if err != nil {
+				t.Errorf("unexpected hijack error: %v", err)
+				return
+			}
+			defer conn.Close()
+			_, _ = rw.WriteString("HTTP/1.1 200 OK\r\nContent-Length: 8\r\nConnection: close\r\n\r\nhijacked")
+			_ = rw.Flush()
+		case "/readfrom":
+			readerFrom, ok := w.(io.ReaderFrom)
+			if !ok {
+				t.Error("response writer should implement io.ReaderFrom")
+				return
+			}
+			_, err := readerFrom.ReadFrom(strings.NewReader("copied"))
+			if err != nil {
+				t.Errorf("unexpected read from error: %v", err)
+			}
+		}
+	})
+
+	server := httptest.NewServer(handler)
+	defer server.Close()
+
+	for path, expected := range map[string]string{"/hijack": "hijacked", "/readfrom": "copied"} {
+		resp, err := http.Get(server.URL + path)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		body, _ := ioutil.ReadAll(resp.Body)
+		_ = resp.Body.Close()
+		if resp.StatusCode != http.StatusOK || string(body) != expected {
+			t.Errorf("unexpected response of %s: %d %s", path, resp.StatusCode, body)
+		}
+	}
+
+	// the recorder does not support hijacking
+	recorder := httptest.NewRecorder()
+	HTTPHandler(m, func(ctx context.Context, w http.ResponseWriter, r *http.Request, data *Data) {
+		_, _, err := w.(http.Hijacker).Hijack()
+		if err == nil {
+			t.Error("hijack should fail when the underlying response writer does not support it")
+		}
+	}).ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/", nil))
+	if recorder.Code != http.StatusOK {
+		t.Errorf("unexpected status code: %d", recorder.Code)
+	}
+}
@@ -0,0 +1,88 @@
+package wrapper
+
+import (
+	"container/list"
+	"context"
+	"sync"
+)
+
+// semaphore limits the total weight acquired at the same time. The waiters are served in FIFO order.
+type semaphore struct {
+	mu      sync.Mutex
+	size    int64
+	cur     int64
+	waiters list.List
+}
+
+type semaphoreWaiter struct {
+	n     int64
+	ready chan struct{}
+}
+
+func newSemaphore(size int64) *semaphore {
+	return &semaphore{size: size}
+}
+
+func (s *semaphore) Acquire(ctx context.Context, n int64) error {
+	s.mu.Lock()
+	if s.size-s.cur >= n && s.waiters.Len() == 0 {
+		s.cur += n
+		s.mu.Unlock()
+		return nil
+	}
+
+	w := &semaphoreWaiter{n: n, ready: make(chan struct{})}
+	elem := s.waiters.PushBack(w)
+	s.mu.Unlock()
+
+	select {
+	case <-w.ready:
+		return nil
+	case <-ctx.Done():
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		select {
+		case <-w.ready:
+			// acquired right after the ctx is done, give it back
+			s.cur -= n
+		default:
+			s.waiters.Remove(elem)
+		}
+		s.notifyWaiters()
+		return ctx.Err()
+	}
+}
+
+// TryAcquire is similar to Acquire, but it returns false instead of waiting
+func (s *semaphore) TryAcquire(n int64) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.size-s.cur >= n && s.waiters.Len() == 0 {
+		s.cur += n
+		return true
+	}
+	return false
+}
+
+func (s *semaphore) Release(n int64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.cur -= n
+	s.notifyWaiters()
+}
+
+func (s *semaphore) notifyWaiters() {
+	for {
+		next := s.waiters.Front()
+		if next == nil {
+			return
+		}
+		w := next.Value.(*semaphoreWaiter)
+		if s.size-s.cur < w.n {
+			return
+		}
+		s.cur += w.n
+		s.waiters.Remove(next)
+		close(w.ready)
+	}
+}